@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// columnStats accumulates the statistics of a pm column
+type columnStats struct {
+	n        int
+	min, max float64
+	mean, m2 float64
+	distinct map[float64]struct{}
+}
+
+func (c *columnStats) add(value float64) {
+	if c.n == 0 {
+		c.min, c.max = value, value
+		c.distinct = make(map[float64]struct{})
+	}
+	c.n++
+	c.min = math.Min(c.min, value)
+	c.max = math.Max(c.max, value)
+	// Welford's online algorithm
+	delta := value - c.mean
+	c.mean += delta / float64(c.n)
+	c.m2 += delta * (value - c.mean)
+	c.distinct[value] = struct{}{}
+}
+
+func (c *columnStats) variance() float64 {
+	if c.n < 2 {
+		return 0
+	}
+	return c.m2 / float64(c.n-1)
+}
+
+// pmAnalyzer collects the numeric pm columns of a capture together with the
+// boiler phase reported by the z records.
+type pmAnalyzer struct {
+	charset  *charset
+	profiles []*pmProfile
+	// profile is selected with the first pm record, its fields are the known fields
+	profile *pmProfile
+
+	phase  string
+	rows   [][]float64
+	phases []string
+	stats  []*columnStats
+}
+
+func newPmAnalyzer(charset *charset, profiles []*pmProfile) *pmAnalyzer {
+	return &pmAnalyzer{charset: charset, profiles: profiles}
+}
+
+// addLine processes a raw line of the capture
+func (a *pmAnalyzer) addLine(line string) {
+	_, raw, _ := parseCaptureLine(line)
+	fields := strings.Fields(a.charset.decode(raw))
+	if len(fields) == 0 {
+		return
+	}
+	switch fields[0] {
+	case "pm":
+		if a.profile == nil {
+			a.profile = selectPmProfile(a.profiles, len(fields))
+		}
+		row := make([]float64, len(fields))
+		for index := 1; index < len(fields); index++ {
+			value, err := strconv.ParseFloat(fields[index], 64)
+			if err != nil {
+				row[index] = math.NaN()
+				continue
+			}
+			row[index] = value
+			for len(a.stats) <= index {
+				a.stats = append(a.stats, &columnStats{})
+			}
+			a.stats[index].add(value)
+		}
+		a.rows = append(a.rows, row)
+		a.phases = append(a.phases, a.phase)
+	case "z":
+		if phase := zPhase(fields); phase != "" {
+			a.phase = phase
+		}
+	}
+}
+
+// zPhase returns the boiler phase started by a z record, "" if the record
+// does not change the phase.
+func zPhase(fields []string) string {
+	switch {
+	case len(fields) >= 4 && fields[2] == "Kessel":
+		return fields[3]
+	case len(fields) >= 4 && (fields[2] == "Störung" || fields[2] == "Stoerung") && fields[3] == "Set":
+		return "Störung"
+	default:
+		return ""
+	}
+}
+
+// column returns the values of the column index of all pm records, NaN where
+// a record has no numeric value.
+func (a *pmAnalyzer) column(index int) []float64 {
+	values := make([]float64, len(a.rows))
+	for i, row := range a.rows {
+		if index < len(row) {
+			values[i] = row[index]
+		} else {
+			values[i] = math.NaN()
+		}
+	}
+	return values
+}
+
+// knownField returns the name of the profile field at index, "" if unknown
+func (a *pmAnalyzer) knownField(index int) string {
+	if a.profile == nil {
+		return ""
+	}
+	for _, field := range a.profile.Fields {
+		if field.Index == index {
+			return field.Node + "/" + field.Id
+		}
+	}
+	return ""
+}
+
+// report writes the statistics of every column
+func (a *pmAnalyzer) report(w io.Writer) {
+	profileName := "-"
+	if a.profile != nil {
+		profileName = a.profile.Name
+	}
+	fmt.Fprintf(w, "%d pm records, pm profile %s\n\n", len(a.rows), profileName)
+
+	var phases []string
+	seen := make(map[string]bool)
+	for _, phase := range a.phases {
+		if phase != "" && !seen[phase] {
+			seen[phase] = true
+			phases = append(phases, phase)
+		}
+	}
+	sort.Strings(phases)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "index\tfield\tn\tmin\tmax\tmean\tvariance\tdistinct\tcorrelation\tphase eta\t")
+	for index := 1; index < len(a.stats); index++ {
+		stats := a.stats[index]
+		if stats.n == 0 {
+			continue
+		}
+		values := a.column(index)
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%g\t%g\t%.3f\t%.3f\t%d\t%s\t%s\t\n",
+			index, orDash(a.knownField(index)), stats.n, stats.min, stats.max, stats.mean, stats.variance(), len(stats.distinct),
+			orDash(a.topCorrelations(index, values, 3)), formatFloat(correlationRatio(values, a.phases)))
+	}
+	tw.Flush()
+
+	if len(phases) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nmean per phase\n\n")
+	fmt.Fprintf(tw, "index\t%s\t\n", strings.Join(phases, "\t"))
+	for index := 1; index < len(a.stats); index++ {
+		if a.stats[index].n == 0 {
+			continue
+		}
+		means := groupMeans(a.column(index), a.phases)
+		cells := make([]string, len(phases))
+		for i, phase := range phases {
+			cells[i] = formatFloat(means[phase])
+		}
+		fmt.Fprintf(tw, "%d\t%s\t\n", index, strings.Join(cells, "\t"))
+	}
+	tw.Flush()
+}
+
+// topCorrelations returns the known fields with the strongest correlation to
+// the column index, e.g. "prozesswerte/kesselTemperatur=0.98".
+func (a *pmAnalyzer) topCorrelations(index int, values []float64, count int) string {
+	if a.profile == nil {
+		return ""
+	}
+	type fieldCorrelation struct {
+		name string
+		r    float64
+	}
+	var correlations []fieldCorrelation
+	for _, field := range a.profile.Fields {
+		if field.Index == index {
+			continue
+		}
+		r := correlation(values, a.column(field.Index))
+		if !math.IsNaN(r) {
+			correlations = append(correlations, fieldCorrelation{field.Node + "/" + field.Id, r})
+		}
+	}
+	sort.SliceStable(correlations, func(i, j int) bool {
+		return math.Abs(correlations[i].r) > math.Abs(correlations[j].r)
+	})
+	var parts []string
+	for i := 0; i < len(correlations) && i < count; i++ {
+		parts = append(parts, fmt.Sprintf("%s=%.2f", correlations[i].name, correlations[i].r))
+	}
+	return strings.Join(parts, " ")
+}
+
+// correlation returns the Pearson correlation of x and y over the pairs
+// without NaN. It is NaN if one of the series is constant.
+func correlation(x, y []float64) float64 {
+	var n, sumX, sumY float64
+	for i := range x {
+		if math.IsNaN(x[i]) || math.IsNaN(y[i]) {
+			continue
+		}
+		n++
+		sumX += x[i]
+		sumY += y[i]
+	}
+	if n < 2 {
+		return math.NaN()
+	}
+	meanX, meanY := sumX/n, sumY/n
+	var cov, varX, varY float64
+	for i := range x {
+		if math.IsNaN(x[i]) || math.IsNaN(y[i]) {
+			continue
+		}
+		dx, dy := x[i]-meanX, y[i]-meanY
+		cov += dx * dy
+		varX += dx * dx
+		varY += dy * dy
+	}
+	if varX == 0 || varY == 0 {
+		return math.NaN()
+	}
+	return cov / math.Sqrt(varX*varY)
+}
+
+// groupMeans returns the mean of the values per group, values without group
+// and NaN values are ignored.
+func groupMeans(values []float64, groups []string) map[string]float64 {
+	sums := make(map[string]float64)
+	counts := make(map[string]float64)
+	for i, value := range values {
+		if groups[i] == "" || math.IsNaN(value) {
+			continue
+		}
+		sums[groups[i]] += value
+		counts[groups[i]]++
+	}
+	means := make(map[string]float64)
+	for group, sum := range sums {
+		means[group] = sum / counts[group]
+	}
+	return means
+}
+
+// correlationRatio returns the correlation ratio eta of the values with the
+// groups: 0 if the group does not tell anything about the value, 1 if the value
+// is determined by the group.
+func correlationRatio(values []float64, groups []string) float64 {
+	means := groupMeans(values, groups)
+	var n, sum float64
+	for i, value := range values {
+		if groups[i] == "" || math.IsNaN(value) {
+			continue
+		}
+		n++
+		sum += value
+	}
+	if n == 0 {
+		return math.NaN()
+	}
+	mean := sum / n
+	var between, total float64
+	for i, value := range values {
+		if groups[i] == "" || math.IsNaN(value) {
+			continue
+		}
+		between += (means[groups[i]] - mean) * (means[groups[i]] - mean)
+		total += (value - mean) * (value - mean)
+	}
+	if total == 0 {
+		return math.NaN()
+	}
+	return math.Sqrt(between / total)
+}
+
+func formatFloat(value float64) string {
+	if math.IsNaN(value) {
+		return "-"
+	}
+	return strconv.FormatFloat(value, 'f', 2, 64)
+}
+
+func orDash(text string) string {
+	if text == "" {
+		return "-"
+	}
+	return text
+}
+
+// runAnalyze implements the analyze subcommand. It prints statistics of the pm
+// columns of a capture to help mapping unknown columns.
+func runAnalyze(args []string) {
+	flags := flag.NewFlagSet("analyze", flag.ExitOnError)
+	charsetName := flags.String("charset", "cp850", "code page of the text in the capture")
+	profileSpec := flags.String("profile", "auto", "pm profiles defining the known fields")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s analyze [-charset name] [-profile profiles] <capture file>\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	charset, err := lookupCharset(*charsetName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	profiles, err := loadPmProfiles(*profileSpec)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	source := &replaySource{path: flags.Arg(0)}
+	capture, err := source.Open()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer capture.Close()
+
+	analyzer := newPmAnalyzer(charset, profiles)
+	scanner := bufio.NewScanner(capture)
+	for scanner.Scan() {
+		analyzer.addLine(scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatalf("could not read %s: %v", flags.Arg(0), err)
+	}
+	analyzer.report(os.Stdout)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCorrelation(t *testing.T) {
+	x := []float64{1, 2, 3, 4, math.NaN()}
+	if r := correlation(x, []float64{2, 4, 6, 8, 1}); math.Abs(r-1) > 1e-9 {
+		t.Fatalf("expected correlation 1, got %v", r)
+	}
+	if r := correlation(x, []float64{8, 6, 4, 2, 1}); math.Abs(r+1) > 1e-9 {
+		t.Fatalf("expected correlation -1, got %v", r)
+	}
+	if r := correlation(x, []float64{5, 5, 5, 5, 5}); !math.IsNaN(r) {
+		t.Fatalf("expected NaN for a constant series, got %v", r)
+	}
+}
+
+func TestCorrelationRatio(t *testing.T) {
+	groups := []string{"Aus", "Aus", "Leistungsbrand", "Leistungsbrand", ""}
+	if eta := correlationRatio([]float64{0, 0, 5, 5, 100}, groups); math.Abs(eta-1) > 1e-9 {
+		t.Fatalf("expected eta 1 for values determined by the phase, got %v", eta)
+	}
+	if eta := correlationRatio([]float64{1, 2, 1, 2, 100}, groups); math.Abs(eta) > 1e-9 {
+		t.Fatalf("expected eta 0 for values independent of the phase, got %v", eta)
+	}
+}
+
+func TestPmAnalyzer_SimulatedCapture(t *testing.T) {
+	sim := newBoilerSimulator(time.Date(2026, 2, 14, 12, 0, 0, 0, time.Local), 10, 1)
+	analyzer := newPmAnalyzer(charsetCP850, builtinPmProfiles)
+	for i := 0; i < 3000; i++ {
+		for _, line := range sim.tick() {
+			analyzer.addLine(line)
+		}
+	}
+
+	if analyzer.profile == nil || analyzer.profile.Name != "hsv" {
+		t.Fatalf("expected profile hsv, got %v", analyzer.profile)
+	}
+	stats := analyzer.stats[4]
+	if stats.n != 3000 || stats.min > stats.mean || stats.mean > stats.max || stats.variance() <= 0 {
+		t.Fatalf("unexpected statistics of the boiler temperature: %+v", stats)
+	}
+	// the O2 content depends on the phase of the boiler
+	if eta := correlationRatio(analyzer.column(3), analyzer.phases); eta < 0.5 {
+		t.Fatalf("expected strong relation of O2 with the phases, got eta %v", eta)
+	}
+
+	var report bytes.Buffer
+	analyzer.report(&report)
+	for _, want := range []string{"3000 pm records, pm profile hsv", "prozesswerte/kesselTemperatur", "Leistungsbrand", "mean per phase"} {
+		if !strings.Contains(report.String(), want) {
+			t.Errorf("expected %q in report:\n%s", want, report.String())
+		}
+	}
+}
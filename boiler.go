@@ -0,0 +1,341 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/creativeprojects/go-homie"
+)
+
+// Boiler is a monitored Hargassner boiler. Every boiler has its own data source
+// and Homie device, its Prometheus metrics carry the label boiler=<ID>.
+type Boiler struct {
+	ID   string
+	Name string
+
+	device           *homie.Device
+	nodeProcessWerte *homie.Node
+	nodeStoerung     *homie.Node
+	nodeKessel       *homie.Node
+
+	stoerungRecord *StoerungRecord
+	kesselRecord   *KesselRecord
+	entaschung     *EntaschungRecord
+	// uhr detects the offset of the boiler clock
+	uhr       *clockDrift
+	statistik *StatistikRecord
+	// cycles records the burn cycles from the Zündung to Aus
+	cycles       *burnCycles
+	fehlzuendung *fehlzuendungRecord
+	meldung      StatusField[string]
+
+	// statusRecord is bound to a pm profile with the first pm record
+	statusRecord *StatusRecord
+	// pmProfiles are the candidates for the pm profile of the boiler
+	pmProfiles []*pmProfile
+	// pmFieldCount is the number of fields of the last pm records and
+	// pmFieldCountRecords the number of consecutive records with this count
+	pmFieldCount        int
+	pmFieldCountRecords int
+	// heizkreise is the number of published heating circuits
+	heizkreise int
+	// raw publishes all pm fields by position, nil if disabled
+	raw *rawPmNode
+	// plausibilityMode defines the handling of implausible pm values
+	plausibilityMode plausibilityMode
+
+	// charset is the code page of the text sent by the boiler
+	charset *charset
+	// watchdog detects a stalled data stream of the boiler
+	watchdog *recordWatchdog
+	// now returns the receive time of a record, the z record times are anchored to its date
+	now    func() time.Time
+	reader *sourceReader
+	// state persists the state of the boiler, nil if disabled
+	state *stateFile
+	// sourceConnected is true while the connection to the boiler is up
+	sourceConnected atomic.Bool
+
+	// mu serializes the processing of records and the access via HTTP
+	mu sync.Mutex
+}
+
+func newBoiler(id, name string) *Boiler {
+	b := &Boiler{
+		ID:               id,
+		Name:             name,
+		device:           homie.NewDevice(id, name),
+		charset:          charsetCP850,
+		pmProfiles:       builtinPmProfiles,
+		heizkreise:       2,
+		plausibilityMode: plausibilitySuppress,
+		watchdog:         newRecordWatchdog(time.Minute),
+		now:              time.Now,
+	}
+	b.nodeProcessWerte = b.device.AddNode("prozesswerte", "Prozesswerte", "Prozesswerte")
+	b.nodeStoerung = b.device.AddNode("stoerung", "Störung", "Störung")
+	b.nodeKessel = b.device.AddNode("kessel", "Kessel", "Kessel")
+
+	b.stoerungRecord = newEmptyStoerungRecord(b.nodeStoerung, id)
+	b.kesselRecord = newEmptyKesselRecord(b.nodeKessel, id)
+	b.entaschung = newEmptyEntaschungRecord(b.nodeKessel, id)
+	b.uhr = newClockDrift(b.nodeKessel, id)
+	b.statistik = newEmptyStatistikRecord(b.device.AddNode("statistik", "Statistik", "Statistik"), id)
+	b.cycles = newBurnCycles(b.nodeKessel, id)
+	b.fehlzuendung = newFehlzuendungRecord(b.nodeKessel, id)
+	b.meldung = newMeldung(b.nodeProcessWerte, id)
+
+	b.statusRecord = newEmptyStatusRecord()
+
+	return b
+}
+
+// handleLine processes a line received from the boiler
+func (b *Boiler) handleLine(line string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.processLine(line)
+}
+
+// pmProfileStableRecords is the number of consecutive pm records with the same
+// number of fields before a profile without exactly this number of fields is
+// selected, so a truncated record after a reconnect does not select a profile.
+const pmProfileStableRecords = 3
+
+// observePmFieldCount selects the pm profile from the number of fields of a pm
+// record. A profile with exactly this number of fields is bound with the first
+// record. Otherwise, and when the number of fields of a bound profile changes,
+// the profile is selected once the count is stable for pmProfileStableRecords
+// records. The caller must hold b.mu.
+func (b *Boiler) observePmFieldCount(fieldCount int) {
+	if fieldCount == b.pmFieldCount {
+		b.pmFieldCountRecords++
+	} else {
+		b.pmFieldCount = fieldCount
+		b.pmFieldCountRecords = 1
+	}
+
+	current := b.statusRecord.profile
+	switch {
+	case current != nil && current.FieldCount == fieldCount:
+	case current == nil && b.pmFieldCountRecords == 1:
+		if profile := selectPmProfile(b.pmProfiles, fieldCount); profile != nil && profile.FieldCount == fieldCount {
+			b.selectPmProfile(fieldCount)
+		}
+	case b.pmFieldCountRecords == pmProfileStableRecords:
+		b.selectPmProfile(fieldCount)
+	}
+}
+
+// pmFieldCountStable reports whether the last pm record has the expected number
+// of fields: the one of the bound profile or the one of pmProfileStableRecords
+// consecutive records. The caller must hold b.mu.
+func (b *Boiler) pmFieldCountStable() bool {
+	if profile := b.statusRecord.profile; profile != nil && profile.FieldCount == b.pmFieldCount {
+		return true
+	}
+	return b.pmFieldCountRecords >= pmProfileStableRecords
+}
+
+// selectPmProfile selects the pm profile matching the number of fields of the
+// pm records. The caller must hold b.mu.
+func (b *Boiler) selectPmProfile(fieldCount int) {
+	profile := selectPmProfile(b.pmProfiles, fieldCount)
+	if profile == nil {
+		return
+	}
+	if current := b.statusRecord.profile; current != nil && current.Name == profile.Name {
+		return
+	}
+	if err := b.applyPmProfile(profile.withHeizkreise(b.heizkreise)); err != nil {
+		log.Printf("could not apply pm profile %s to %s: %v", profile.Name, b.ID, err)
+		return
+	}
+	log.Printf("Selected pm profile %s for %s (%d fields)", profile.Name, b.ID, fieldCount)
+}
+
+// updateHomieState publishes the device state derived from the health of the
+// boiler connection and the data stream.
+func (b *Boiler) updateHomieState() {
+	if b.sourceConnected.Load() && !b.watchdog.Stalled() {
+		b.setHomieState(homie.StateReady)
+	} else {
+		b.setHomieState(homie.StateAlert)
+	}
+}
+
+// setHomieState publishes the device state. The device is not safe for
+// concurrent use, the state is set under b.mu like the records.
+func (b *Boiler) setHomieState(state homie.DeviceState) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.device.SetState(state)
+}
+
+// rolloverStatistik starts a new day of the statistics at local midnight
+func (b *Boiler) rolloverStatistik() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.statistik.rollover(b.now())
+}
+
+func (b *Boiler) publishHomieAttributes() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sendHomieAttributes()
+}
+
+// sendHomieAttributes publishes the Homie attributes, the caller must hold b.mu.
+func (b *Boiler) sendHomieAttributes() {
+	// get the full homie definition to send to MQTT - you only need to send it once unless it's changing over time
+	for _, attribute := range b.device.GetHomieAttributes() {
+		mqttClient.Publish(attribute.Topic, 0, true, attribute.Value)
+	}
+}
+
+// configuredBoilerIDs returns the IDs of the boilers listed in HARGASSNER_BOILERS.
+// Without a list a single boiler with the ID from HARGASSNER_HOMIE_DEVICE_ID is monitored.
+func configuredBoilerIDs() []string {
+	list := getEnv("HARGASSNER_BOILERS", "")
+	if list == "" {
+		return []string{getEnv("HARGASSNER_HOMIE_DEVICE_ID", "hargassner")}
+	}
+	var ids []string
+	for _, id := range strings.Split(list, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// boilerEnvName returns the name of the environment variable holding the
+// setting name for a boiler. A boiler specific variable HARGASSNER_<ID>_<name>
+// takes precedence over the common variable HARGASSNER_<name>.
+func boilerEnvName(id, name string) string {
+	specific := "HARGASSNER_" + strings.ToUpper(strings.ReplaceAll(id, "-", "_")) + "_" + name
+	if _, ok := os.LookupEnv(specific); ok {
+		return specific
+	}
+	return "HARGASSNER_" + name
+}
+
+// newConfiguredBoiler creates a boiler with the settings from the environment.
+// The data source is not opened.
+func newConfiguredBoiler(id string, multiple bool) (*Boiler, error) {
+	if !homie.IsValidID(id) {
+		return nil, fmt.Errorf("invalid boiler ID %q: only letters, digits and '-' are allowed", id)
+	}
+
+	defaultName := "Hargassner Heizung"
+	if multiple {
+		defaultName += " (" + id + ")"
+	}
+	b := newBoiler(id, getEnv(boilerEnvName(id, "NAME"), defaultName))
+
+	charsetEnv := boilerEnvName(id, "CHARSET")
+	charset, err := lookupCharset(getEnv(charsetEnv, "cp850"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", charsetEnv, err)
+	}
+	b.charset = charset
+
+	b.watchdog.timeout = getEnvDuration(boilerEnvName(id, "STALL_TIMEOUT"), time.Minute)
+	b.uhr.threshold = getEnvDuration(boilerEnvName(id, "CLOCK_DRIFT_THRESHOLD"), 5*time.Minute)
+	b.fehlzuendung.timeout = getEnvDuration(boilerEnvName(id, "IGNITION_TIMEOUT"), 30*time.Minute)
+
+	cycleHistoryEnv := boilerEnvName(id, "CYCLE_HISTORY")
+	b.cycles.size, err = strconv.Atoi(getEnv(cycleHistoryEnv, "20"))
+	if err != nil || b.cycles.size < 0 {
+		return nil, fmt.Errorf("invalid %s: %q", cycleHistoryEnv, getEnv(cycleHistoryEnv, "20"))
+	}
+
+	heizkreiseEnv := boilerEnvName(id, "HEIZKREISE")
+	b.heizkreise, err = strconv.Atoi(getEnv(heizkreiseEnv, "2"))
+	if err != nil || b.heizkreise < 0 {
+		return nil, fmt.Errorf("invalid %s: %q", heizkreiseEnv, getEnv(heizkreiseEnv, "2"))
+	}
+
+	plausibilityEnv := boilerEnvName(id, "PLAUSIBILITY")
+	b.plausibilityMode, err = parsePlausibilityMode(getEnv(plausibilityEnv, "suppress"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", plausibilityEnv, err)
+	}
+
+	if getEnv(boilerEnvName(id, "PM_RAW"), "false") == "true" {
+		b.raw = newRawPmNode(b.device, id)
+	}
+
+	profileEnv := boilerEnvName(id, "PM_PROFILE")
+	b.pmProfiles, err = loadPmProfiles(getEnv(profileEnv, "auto"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", profileEnv, err)
+	}
+	return b, nil
+}
+
+// newBoilerReader creates the reader for the data source of a boiler
+// including the optional recorder of the raw stream.
+func newBoilerReader(b *Boiler) (*sourceReader, error) {
+	sourceEnv := boilerEnvName(b.ID, "SERIAL_DEVICE")
+	source, err := parseLineSource(getEnv(sourceEnv, "/dev/ttyUSB0"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", sourceEnv, err)
+	}
+
+	reader := newSourceReader(source)
+	reader.backoff = newBackoff(
+		getEnvDuration(boilerEnvName(b.ID, "RECONNECT_MIN_DELAY"), time.Second),
+		getEnvDuration(boilerEnvName(b.ID, "RECONNECT_MAX_DELAY"), time.Minute))
+
+	if captureDir := getEnv(boilerEnvName(b.ID, "CAPTURE_DIR"), ""); captureDir != "" {
+		maxSizeEnv := boilerEnvName(b.ID, "CAPTURE_MAX_SIZE_MB")
+		maxSizeMB, err := strconv.Atoi(getEnv(maxSizeEnv, "10"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", maxSizeEnv, err)
+		}
+		compress := getEnv(boilerEnvName(b.ID, "CAPTURE_COMPRESS"), "false") == "true"
+		recorder, err := newCaptureRecorder(captureDir, b.ID, int64(maxSizeMB)*1024*1024, compress)
+		if err != nil {
+			return nil, err
+		}
+		reader.recorder = recorder
+		log.Printf("Recording raw stream of %s to %s", b.ID, captureDir)
+	}
+	return reader, nil
+}
+
+// findBoiler returns the boiler addressed by the {boiler} path value of a
+// request. Without path value the first boiler is returned.
+func findBoiler(boilers []*Boiler, r *http.Request) *Boiler {
+	id := r.PathValue("boiler")
+	if id == "" {
+		return boilers[0]
+	}
+	for _, b := range boilers {
+		if b.ID == id {
+			return b
+		}
+	}
+	return nil
+}
+
+// boilerHandler returns a handler calling handle for the boiler addressed by
+// the request, unknown boilers are answered with 404.
+func boilerHandler(boilers []*Boiler, handle func(b *Boiler, w http.ResponseWriter, r *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		b := findBoiler(boilers, r)
+		if b == nil {
+			http.Error(w, "Unknown boiler", http.StatusNotFound)
+			return
+		}
+		handle(b, w, r)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// charset is a single byte code page used by the boiler for its text output.
+// The lower half is ASCII, high holds the characters of the bytes 0x80 to 0xFF.
+type charset struct {
+	name string
+	high []rune
+}
+
+var charsetCP850 = newCharset("cp850",
+	"ÇüéâäàåçêëèïîìÄÅ"+
+		"ÉæÆôöòûùÿÖÜø£Ø×ƒ"+
+		"áíóúñÑªº¿®¬½¼¡«»"+
+		"░▒▓│┤ÁÂÀ©╣║╗╝¢¥┐"+
+		"└┴┬├─┼ãÃ╚╔╩╦╠═╬¤"+
+		"ðÐÊËÈıÍÎÏ┘┌█▄¦Ì▀"+
+		"ÓßÔÒõÕµþÞÚÛÙýÝ¯´"+
+		"\u00ad±‗¾¶§÷¸°¨·¹³²■\u00a0")
+
+var charsetISO88591 = func() *charset {
+	high := make([]rune, 128)
+	for i := range high {
+		high[i] = rune(0x80 + i)
+	}
+	return &charset{name: "iso-8859-1", high: high}
+}()
+
+func newCharset(name, high string) *charset {
+	runes := []rune(high)
+	if len(runes) != 128 {
+		panic(fmt.Sprintf("charset %s has %d instead of 128 characters", name, len(runes)))
+	}
+	return &charset{name: name, high: runes}
+}
+
+// lookupCharset returns the charset configured by name.
+func lookupCharset(name string) (*charset, error) {
+	switch strings.ToLower(name) {
+	case "cp850", "ibm850":
+		return charsetCP850, nil
+	case "iso-8859-1", "iso8859-1", "latin1":
+		return charsetISO88591, nil
+	default:
+		return nil, fmt.Errorf("unsupported charset %q (supported: cp850, iso-8859-1)", name)
+	}
+}
+
+// decode converts a line received from the boiler to UTF-8. Lines which are
+// valid UTF-8 already are returned unchanged.
+func (c *charset) decode(line string) string {
+	if utf8.ValidString(line) {
+		return line
+	}
+	var b strings.Builder
+	b.Grow(len(line) + len(line)/4)
+	for i := 0; i < len(line); i++ {
+		if ch := line[i]; ch < 0x80 {
+			b.WriteByte(ch)
+		} else {
+			b.WriteRune(c.high[ch-0x80])
+		}
+	}
+	return b.String()
+}
+
+// encode converts UTF-8 text to the code page. Characters which are not part
+// of the code page are replaced by '?'.
+func (c *charset) encode(text string) string {
+	var b strings.Builder
+	b.Grow(len(text))
+	for _, r := range text {
+		if r < 0x80 {
+			b.WriteRune(r)
+			continue
+		}
+		index := -1
+		for i, h := range c.high {
+			if h == r {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			b.WriteByte('?')
+		} else {
+			b.WriteByte(byte(0x80 + index))
+		}
+	}
+	return b.String()
+}
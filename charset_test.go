@@ -0,0 +1,49 @@
+package main
+
+import "testing"
+
+func TestCharset_Decode(t *testing.T) {
+	tests := []struct {
+		charset *charset
+		in      string
+		want    string
+	}{
+		{charsetCP850, "Z\x81ndung", "Zündung"},
+		{charsetCP850, "St\x94rung Gebl\x84se", "Störung Gebläse"},
+		{charsetCP850, "F\x81hler \xe1", "Fühler ß"},
+		{charsetISO88591, "Z\xfcndung Gebl\xe4se", "Zündung Gebläse"},
+		// valid UTF-8 is passed through
+		{charsetCP850, "Zündung", "Zündung"},
+	}
+	for _, tt := range tests {
+		if got := tt.charset.decode(tt.in); got != tt.want {
+			t.Errorf("%s.decode(%q) = %q, want %q", tt.charset.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCharset_EncodeRoundTrip(t *testing.T) {
+	for _, text := range []string{"Zündung", "Störung Quit 0010", "Entaschung Gebläse"} {
+		encoded := charsetCP850.encode(text)
+		if encoded == text {
+			t.Fatalf("expected %q to be encoded", text)
+		}
+		if decoded := charsetCP850.decode(encoded); decoded != text {
+			t.Fatalf("round trip of %q returned %q", text, decoded)
+		}
+	}
+	if got := charsetCP850.encode("€"); got != "?" {
+		t.Fatalf("expected unknown character to be replaced, got %q", got)
+	}
+}
+
+func TestLookupCharset(t *testing.T) {
+	for _, name := range []string{"cp850", "CP850", "iso-8859-1", "latin1"} {
+		if _, err := lookupCharset(name); err != nil {
+			t.Fatalf("lookupCharset(%q) failed: %v", name, err)
+		}
+	}
+	if _, err := lookupCharset("ebcdic"); err == nil {
+		t.Fatalf("expected error for unsupported charset")
+	}
+}
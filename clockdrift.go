@@ -0,0 +1,55 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/creativeprojects/go-homie"
+)
+
+// clockDrift compares the clock of the boiler with the host clock
+type clockDrift struct {
+	// Abweichung is the offset of the boiler clock, positive if the boiler clock is ahead
+	Abweichung StatusField[int]
+	// Alarm is true while the offset exceeds threshold
+	Alarm StatusField[bool]
+	// threshold is the maximum offset before the alarm is raised, 0 disables the alarm
+	threshold time.Duration
+	boilerID  string
+}
+
+func newClockDrift(node *homie.Node, boilerID string) *clockDrift {
+	ret := &clockDrift{
+		Abweichung: StatusField[int]{Id: "uhrAbweichung", Name: MultiLanguageString{EN: "Clock Offset", DE: "Abweichung der Kesseluhr"}, Unit: "s"},
+		Alarm:      StatusField[bool]{Id: "uhrAbweichungAlarm", Name: MultiLanguageString{EN: "Clock Offset Alarm", DE: "Alarm Abweichung der Kesseluhr"}, Unit: ""},
+		threshold:  5 * time.Minute,
+		boilerID:   boilerID,
+	}
+
+	registerStatusField(&ret.Abweichung, node, "kessel", boilerID)
+	registerStatusField(&ret.Alarm, node, "kessel", boilerID)
+
+	return ret
+}
+
+// update takes the time of a z record reported by the boiler clock and the
+// time the record was received. The z records carry whole seconds, the offset
+// is accurate to about one second.
+func (d *clockDrift) update(boilerTime, received time.Time) {
+	offset := boilerTime.Sub(received).Round(time.Second)
+	d.Abweichung.SetValue(int(offset.Seconds()))
+
+	alarm := d.threshold > 0 && absDuration(offset) > d.threshold
+	if alarm && !d.Alarm.Value {
+		log.Printf("Clock of %s is off by %s, please set the clock of the boiler", d.boilerID, offset)
+	} else if !alarm && d.Alarm.Value {
+		log.Printf("Clock of %s is off by %s only", d.boilerID, offset)
+	}
+	d.Alarm.SetValue(alarm)
+}
+
+// boilerTime converts a host time to the boiler clock with the offset of the
+// last z record
+func (d *clockDrift) boilerTime(host time.Time) time.Time {
+	return host.Add(time.Duration(d.Abweichung.Value) * time.Second)
+}
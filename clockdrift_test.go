@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestClockDrift(t *testing.T) {
+	b := newBoiler("clock-drift", "Clock Drift")
+	received := time.Date(2026, 2, 14, 14, 10, 38, 0, time.UTC)
+	b.now = func() time.Time { return received }
+
+	b.processLine("z 14:10:40 Kessel Zündung")
+	if b.uhr.Abweichung.Value != 2 || b.uhr.Alarm.Value {
+		t.Fatalf("expected offset 2s without alarm, got %d %v", b.uhr.Abweichung.Value, b.uhr.Alarm.Value)
+	}
+
+	// log excerpt of the spec: the boiler reports 14:10:40 at 13:21:44
+	received = time.Date(2026, 2, 14, 13, 21, 44, 0, time.UTC)
+	b.processLine("z 14:10:40 Kessel Zündung")
+	if b.uhr.Abweichung.Value != 2936 || !b.uhr.Alarm.Value {
+		t.Fatalf("expected offset 2936s with alarm, got %d %v", b.uhr.Abweichung.Value, b.uhr.Alarm.Value)
+	}
+	if got := testutil.ToFloat64(b.uhr.Abweichung.PromGauge); got != 2936 {
+		t.Fatalf("expected gauge 2936, got %v", got)
+	}
+
+	// a boiler clock behind the host clock over midnight
+	received = time.Date(2026, 2, 15, 0, 1, 0, 0, time.UTC)
+	b.processLine("z 23:59:00 Kessel Aus")
+	if b.uhr.Abweichung.Value != -120 || b.uhr.Alarm.Value {
+		t.Fatalf("expected offset -120s without alarm, got %d %v", b.uhr.Abweichung.Value, b.uhr.Alarm.Value)
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/creativeprojects/go-homie"
+)
+
+// CycleValueStats are the statistics of a pm value during a burn cycle
+type CycleValueStats struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+	Avg float64 `json:"avg"`
+
+	n   int
+	sum float64
+}
+
+func (s *CycleValueStats) add(value float64) {
+	if s.n == 0 || value < s.Min {
+		s.Min = value
+	}
+	if s.n == 0 || value > s.Max {
+		s.Max = value
+	}
+	s.n++
+	s.sum += value
+	s.Avg = math.Round(s.sum/float64(s.n)*100) / 100
+}
+
+// BurnCycle is a burn cycle of the boiler from the Zündung to Aus
+type BurnCycle struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// durations of the phases in seconds
+	ZuendungDauer       int `json:"zuendungDauer"`
+	LeistungsbrandDauer int `json:"leistungsbrandDauer"`
+	EntaschungDauer     int `json:"entaschungDauer"`
+	// Werte are the statistics of the pm values during the cycle keyed by property ID
+	Werte map[string]*CycleValueStats `json:"werte"`
+
+	leistungsbrandStart time.Time
+	entaschungStart     time.Time
+}
+
+// cycleValues returns the pm values recorded in the burn cycles
+func (r *StatusRecord) cycleValues() []pmValue {
+	return []pmValue{
+		&r.BoilerTemperature, &r.ExhaustGasTemperature, &r.O2InExhaustGas, &r.FeedRate,
+		&r.MotorCurrentFeedScrew, &r.MotorCurrentAshDischarge, &r.MotorCurrentRoomDischarge,
+	}
+}
+
+// pmValue is a numeric status field
+type pmValue interface {
+	pmField
+	floatValue() (id string, value float64, ok bool)
+}
+
+// floatValue returns the value of a numeric field, ok is false if the field
+// is not numeric.
+func (field *StatusField[T]) floatValue() (string, float64, bool) {
+	switch v := any(field.Value).(type) {
+	case int:
+		return field.Id, float64(v), true
+	case float64:
+		return field.Id, v, true
+	default:
+		return field.Id, 0, false
+	}
+}
+
+// burnCycles records the burn cycles of the boiler
+type burnCycles struct {
+	// LetzterZyklus publishes the last finished cycle as JSON
+	LetzterZyklus StatusField[string]
+
+	current *BurnCycle
+	history []*BurnCycle
+	// size is the number of cycles kept
+	size int
+}
+
+func newBurnCycles(node *homie.Node, boilerID string) *burnCycles {
+	ret := &burnCycles{
+		LetzterZyklus: StatusField[string]{Id: "letzterZyklus", Name: MultiLanguageString{EN: "Last Burn Cycle", DE: "Letzter Brennzyklus"}, Unit: ""},
+		size:          20,
+	}
+	registerStatusField(&ret.LetzterZyklus, node, "kessel", boilerID)
+	return ret
+}
+
+// start starts a cycle with the Zündung at timestamp
+func (c *burnCycles) start(timestamp time.Time) {
+	c.current = &BurnCycle{Start: timestamp, Werte: make(map[string]*CycleValueStats)}
+}
+
+func (c *burnCycles) leistungsbrand(timestamp time.Time) {
+	if c.current != nil && c.current.leistungsbrandStart.IsZero() {
+		c.current.leistungsbrandStart = timestamp
+	}
+}
+
+func (c *burnCycles) entaschung(timestamp time.Time) {
+	if c.current != nil && c.current.entaschungStart.IsZero() {
+		c.current.entaschungStart = timestamp
+	}
+}
+
+// sample adds the values set by the last pm record to the running cycle
+func (c *burnCycles) sample(record *StatusRecord) {
+	if c.current == nil {
+		return
+	}
+	for _, field := range record.cycleValues() {
+		if !record.received[field] {
+			continue
+		}
+		id, value, ok := field.floatValue()
+		if !ok {
+			continue
+		}
+		stats := c.current.Werte[id]
+		if stats == nil {
+			stats = &CycleValueStats{}
+			c.current.Werte[id] = stats
+		}
+		stats.add(value)
+	}
+}
+
+// end finishes the running cycle at timestamp and publishes it
+func (c *burnCycles) end(timestamp time.Time) {
+	cycle := c.current
+	if cycle == nil {
+		return
+	}
+	c.current = nil
+	cycle.End = timestamp
+
+	seconds := func(from, to time.Time) int {
+		return int(to.Sub(from).Seconds())
+	}
+	brennEnde := timestamp
+	if !cycle.entaschungStart.IsZero() {
+		brennEnde = cycle.entaschungStart
+		cycle.EntaschungDauer = seconds(cycle.entaschungStart, timestamp)
+	}
+	if cycle.leistungsbrandStart.IsZero() {
+		cycle.ZuendungDauer = seconds(cycle.Start, brennEnde)
+	} else {
+		cycle.ZuendungDauer = seconds(cycle.Start, cycle.leistungsbrandStart)
+		cycle.LeistungsbrandDauer = seconds(cycle.leistungsbrandStart, brennEnde)
+	}
+
+	c.history = append(c.history, cycle)
+	if len(c.history) > c.size {
+		c.history = c.history[len(c.history)-c.size:]
+	}
+
+	data, err := json.Marshal(cycle)
+	if err != nil {
+		log.Printf("could not encode burn cycle: %v", err)
+		return
+	}
+	c.LetzterZyklus.SetValue(string(data))
+}
+
+// handleCycles returns the recorded burn cycles of the boiler as JSON, the
+// oldest first.
+func (b *Boiler) handleCycles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	b.mu.Lock()
+	data, err := json.Marshal(append([]*BurnCycle{}, b.cycles.history...))
+	b.mu.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
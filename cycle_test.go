@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBurnCycle(t *testing.T) {
+	b := newBoiler("cycle", "Cycle")
+	b.now = testClock
+	b.plausibilityMode = plausibilityOff
+
+	// pm records outside of a cycle are not recorded
+	b.processLine(hsvLine(50))
+	for _, line := range []string{
+		"z 14:10:40 Kessel Zündung",
+		hsvLine(60),
+		"z 14:20:20 Kessel Leistungsbrand",
+		hsvLine(70),
+		hsvLine(80),
+		// a truncated record does not count the last values again
+		strings.Join(strings.Fields(hsvLine(90))[:10], " "),
+		"z 17:50:18 Kessel Entaschung Start",
+		"z 18:00:32 Kessel Aus",
+	} {
+		b.processLine(line)
+	}
+	b.processLine(hsvLine(40))
+
+	if len(b.cycles.history) != 1 {
+		t.Fatalf("expected 1 cycle, got %d", len(b.cycles.history))
+	}
+	cycle := b.cycles.history[0]
+	// 14:10:40 bis 14:20:20, 14:20:20 bis 17:50:18 und 17:50:18 bis 18:00:32
+	if cycle.ZuendungDauer != 580 || cycle.LeistungsbrandDauer != 12598 || cycle.EntaschungDauer != 614 {
+		t.Fatalf("unexpected phase durations %+v", cycle)
+	}
+	temperatur := cycle.Werte["kesselTemperatur"]
+	if temperatur == nil || temperatur.Min != 60 || temperatur.Max != 80 || temperatur.Avg != 70 {
+		t.Fatalf("unexpected kesselTemperatur %+v", temperatur)
+	}
+	if o2 := cycle.Werte["o2InAbgas"]; o2 == nil || o2.Avg != 7.5 {
+		t.Fatalf("unexpected o2InAbgas %+v", o2)
+	}
+
+	var published BurnCycle
+	if err := json.Unmarshal([]byte(b.cycles.LetzterZyklus.Value), &published); err != nil {
+		t.Fatalf("invalid cycle JSON %q: %v", b.cycles.LetzterZyklus.Value, err)
+	}
+	if !published.Start.Equal(cycle.Start) || !published.End.Equal(cycle.End) || published.Werte["kesselTemperatur"].Max != 80 {
+		t.Fatalf("unexpected published cycle %+v", published)
+	}
+
+	// Aus without Zündung is no cycle
+	b.processLine("z 19:00:00 Kessel Leistungsbrand")
+	b.processLine("z 20:00:00 Kessel Aus")
+	if len(b.cycles.history) != 1 {
+		t.Fatalf("expected 1 cycle, got %d", len(b.cycles.history))
+	}
+}
+
+func TestBurnCycleHistory(t *testing.T) {
+	b := newBoiler("cycle-history", "Cycle History")
+	b.now = testClock
+	b.cycles.size = 2
+	for _, line := range []string{
+		"z 10:00:00 Kessel Zündung", "z 10:10:00 Kessel Aus",
+		"z 11:00:00 Kessel Zündung", "z 11:10:00 Kessel Leistungsbrand", "z 12:00:00 Kessel Aus",
+		"z 13:00:00 Kessel Zündung", "z 13:05:00 Kessel Leistungsbrand", "z 14:00:00 Kessel Aus",
+	} {
+		b.processLine(line)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/cycles/{boiler}", boilerHandler([]*Boiler{b}, (*Boiler).handleCycles))
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/cycles/cycle-history", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rr.Code, rr.Body.String())
+	}
+	var cycles []BurnCycle
+	if err := json.Unmarshal(rr.Body.Bytes(), &cycles); err != nil {
+		t.Fatal(err)
+	}
+	if len(cycles) != 2 {
+		t.Fatalf("expected the last 2 cycles, got %d", len(cycles))
+	}
+	if cycles[0].Start.Hour() != 11 || cycles[1].Start.Hour() != 13 {
+		t.Fatalf("unexpected cycles %+v", cycles)
+	}
+	if cycles[0].ZuendungDauer != 600 || cycles[0].LeistungsbrandDauer != 3000 {
+		t.Fatalf("unexpected phase durations %+v", cycles[0])
+	}
+}
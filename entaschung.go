@@ -0,0 +1,115 @@
+package main
+
+import (
+	"time"
+
+	"github.com/creativeprojects/go-homie"
+)
+
+// entaschungSchritte are the steps of an ash removal reported as
+// "z <time> Kessel Entaschung <step>" with the IDs of their properties
+var entaschungSchritte = []struct{ step, id string }{
+	{"Start", "DauerEntaschungStart"},
+	{"Gebläse", "DauerEntaschungGeblaese"},
+	{"Rost", "DauerEntaschungRost"},
+}
+
+// EntaschungRecord holds the ash removal cycles of the boiler
+type EntaschungRecord struct {
+	AnzahlEntaschungen    StatusField[int]
+	DauerLetzteEntaschung StatusField[int]
+	// DauerSchritt holds the durations of the steps of the last ash removal
+	DauerSchritt     map[string]*StatusField[int]
+	LetzteEntaschung StatusField[string]
+	// MaxStromAscheaustragung is the peak motor current of the ash discharge during the last ash removal
+	MaxStromAscheaustragung StatusField[float64]
+
+	// start is the start of the running ash removal, zero outside of an ash removal
+	start     time.Time
+	step      string
+	stepStart time.Time
+	dauer     map[string]time.Duration
+	// maxStrom is the peak motor current of the running ash removal, hasStrom
+	// is true once a value was received
+	maxStrom float64
+	hasStrom bool
+}
+
+func newEmptyEntaschungRecord(node *homie.Node, boilerID string) *EntaschungRecord {
+	ret := &EntaschungRecord{
+		AnzahlEntaschungen:      StatusField[int]{Id: "AnzahlEntaschungen", Name: MultiLanguageString{EN: "Number of Ash Removals", DE: "Anzahl Entaschungen"}, Unit: ""},
+		DauerLetzteEntaschung:   StatusField[int]{Id: "DauerLetzteEntaschung", Name: MultiLanguageString{EN: "Duration Last Ash Removal", DE: "Dauer letzte Entaschung"}, Unit: "s"},
+		LetzteEntaschung:        StatusField[string]{Id: "LetzteEntaschung", Name: MultiLanguageString{EN: "Last Ash Removal", DE: "Letzte Entaschung"}, Unit: ""},
+		MaxStromAscheaustragung: StatusField[float64]{Id: "MaxStromAscheaustragung", Name: MultiLanguageString{EN: "Peak Motor Current Ash Discharge", DE: "Maximaler Strom Ascheaustragung"}, Unit: "A"},
+		DauerSchritt:            make(map[string]*StatusField[int]),
+	}
+
+	registerStatusField(&ret.AnzahlEntaschungen, node, "kessel", boilerID)
+	registerStatusField(&ret.DauerLetzteEntaschung, node, "kessel", boilerID)
+	for _, schritt := range entaschungSchritte {
+		field := &StatusField[int]{
+			Id:   schritt.id,
+			Name: MultiLanguageString{EN: "Duration Last Ash Removal Step " + schritt.step, DE: "Dauer letzte Entaschung Schritt " + schritt.step},
+			Unit: "s",
+		}
+		registerStatusField(field, node, "kessel", boilerID)
+		ret.DauerSchritt[schritt.step] = field
+	}
+	registerStatusField(&ret.LetzteEntaschung, node, "kessel", boilerID)
+	registerStatusField(&ret.MaxStromAscheaustragung, node, "kessel", boilerID)
+
+	return ret
+}
+
+// startSchritt starts a step of the ash removal at timestamp. The step
+// "Start" or the first step after another Kessel event starts a new ash
+// removal and reports whether a new ash removal was started.
+func (r *EntaschungRecord) startSchritt(step string, timestamp time.Time) bool {
+	started := r.start.IsZero() || step == "Start"
+	if started {
+		r.end(timestamp)
+		r.start = timestamp
+		r.dauer = make(map[string]time.Duration)
+		r.maxStrom, r.hasStrom = 0, false
+		r.AnzahlEntaschungen.SetValue(r.AnzahlEntaschungen.Value + 1)
+		r.LetzteEntaschung.SetValue(timestamp.Format(time.RFC3339))
+	} else {
+		r.endSchritt(timestamp)
+	}
+	r.step = step
+	r.stepStart = timestamp
+	return started
+}
+
+func (r *EntaschungRecord) endSchritt(timestamp time.Time) {
+	if r.step != "" {
+		r.dauer[r.step] += timestamp.Sub(r.stepStart)
+		r.step = ""
+	}
+}
+
+// end publishes the durations of the running ash removal ending at timestamp
+func (r *EntaschungRecord) end(timestamp time.Time) {
+	if r.start.IsZero() {
+		return
+	}
+	r.endSchritt(timestamp)
+	r.DauerLetzteEntaschung.SetValue(int(timestamp.Sub(r.start).Seconds()))
+	for _, schritt := range entaschungSchritte {
+		r.DauerSchritt[schritt.step].SetValue(int(r.dauer[schritt.step].Seconds()))
+	}
+	if r.hasStrom {
+		r.MaxStromAscheaustragung.SetValue(r.maxStrom)
+	}
+	r.start = time.Time{}
+}
+
+// observeStrom records a value of the motor current of the ash discharge
+func (r *EntaschungRecord) observeStrom(value float64) {
+	if r.start.IsZero() {
+		return
+	}
+	if !r.hasStrom || value > r.maxStrom {
+		r.maxStrom, r.hasStrom = value, true
+	}
+}
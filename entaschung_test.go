@@ -0,0 +1,89 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEntaschung(t *testing.T) {
+	b := newBoiler("entaschung", "Entaschung")
+	b.now = testClock
+	entaschung := b.entaschung
+
+	// pm record with the motor current of the ash discharge
+	pmLine := func(strom string) string {
+		fields := strings.Fields(hsvLine(71))
+		fields[30] = strom
+		return strings.Join(fields, " ")
+	}
+
+	for _, line := range []string{
+		"z 14:20:20 Kessel Leistungsbrand",
+		pmLine("3.00"),
+		"z 17:50:18 Kessel Entaschung Start",
+		"z 17:50:18 Kessel Entaschung Gebläse",
+		pmLine("1.20"),
+		pmLine("2.40"),
+		"z 17:59:58 Kessel Entaschung Rost",
+		pmLine("1.80"),
+		"z 18:00:32 Kessel Aus",
+		pmLine("0.50"),
+	} {
+		b.processLine(line)
+	}
+
+	if entaschung.AnzahlEntaschungen.Value != 1 {
+		t.Fatalf("expected 1 Entaschung, got %d", entaschung.AnzahlEntaschungen.Value)
+	}
+	// 17:50:18 bis 18:00:32 sind 10 Minuten und 14 Sekunden
+	if entaschung.DauerLetzteEntaschung.Value != 614 {
+		t.Fatalf("expected DauerLetzteEntaschung 614, got %d", entaschung.DauerLetzteEntaschung.Value)
+	}
+	for step, want := range map[string]int{"Start": 0, "Gebläse": 580, "Rost": 34} {
+		if got := entaschung.DauerSchritt[step].Value; got != want {
+			t.Errorf("expected duration %d of step %s, got %d", want, step, got)
+		}
+	}
+	if entaschung.LetzteEntaschung.Value != "2026-02-14T17:50:18Z" {
+		t.Fatalf("unexpected LetzteEntaschung %q", entaschung.LetzteEntaschung.Value)
+	}
+	if entaschung.MaxStromAscheaustragung.Value != 2.4 {
+		t.Fatalf("expected peak current 2.4 during the Entaschung, got %v", entaschung.MaxStromAscheaustragung.Value)
+	}
+	if b.device.Node("kessel").Property("DauerEntaschungGeblaese") == nil {
+		t.Fatalf("expected Homie property DauerEntaschungGeblaese")
+	}
+
+	// a step without preceding Start starts a new Entaschung
+	b.processLine("z 20:00:00 Kessel Entaschung Rost")
+	b.processLine("z 20:01:00 Kessel Leistungsbrand")
+	if entaschung.AnzahlEntaschungen.Value != 2 || entaschung.DauerLetzteEntaschung.Value != 60 {
+		t.Fatalf("expected second Entaschung of 60s, got %d %d", entaschung.AnzahlEntaschungen.Value, entaschung.DauerLetzteEntaschung.Value)
+	}
+}
+
+func TestEntaschung_IgnoresRejectedStrom(t *testing.T) {
+	b := newBoiler("entaschung-rejected", "Entaschung Rejected")
+	b.now = testClock
+	pmLine := func(strom string) string {
+		fields := strings.Fields(hsvLine(71))
+		fields[30] = strom
+		return strings.Join(fields, " ")
+	}
+
+	for _, line := range []string{
+		pmLine("3.00"),
+		"z 17:50:18 Kessel Entaschung Start",
+		// malformed and implausible values keep the last value of the Leistungsbrand
+		pmLine("x.yz"),
+		pmLine("50.00"),
+		pmLine("1.50"),
+		"z 18:00:32 Kessel Aus",
+	} {
+		b.processLine(line)
+	}
+
+	if got := b.entaschung.MaxStromAscheaustragung.Value; got != 1.5 {
+		t.Fatalf("expected peak current 1.5 of the received values, got %v", got)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"log"
+	"math"
+	"time"
+
+	"github.com/creativeprojects/go-homie"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// reasons of a failed ignition
+const (
+	fehlzuendungAus      = "aus"
+	fehlzuendungStoerung = "stoerung"
+	fehlzuendungTimeout  = "timeout"
+)
+
+var fehlzuendungenTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "hargassner_fehlzuendungen_total",
+	Help: "Anzahl der fehlgeschlagenen Zündungen (reason aus, stoerung oder timeout)",
+}, []string{"boiler", "reason"})
+
+func init() {
+	prometheus.MustRegister(fehlzuendungenTotal)
+}
+
+// fehlzuendungRecord detects ignitions which do not reach the Leistungsbrand.
+// An ignition fails if it ends with Aus or a Störung or if it takes longer
+// than timeout.
+type fehlzuendungRecord struct {
+	AnzahlFehlzuendungen         StatusField[int]
+	AnzahlErfolgreicheZuendungen StatusField[int]
+	// Quote is the percentage of failed ignitions of all finished ignitions
+	Quote StatusField[float64]
+	// LetzteFehlzuendung is the time of the last failed ignition in RFC 3339 format
+	LetzteFehlzuendung StatusField[string]
+	Grund              StatusField[string]
+
+	// timeout is the maximum duration of an ignition, 0 disables the check
+	timeout time.Duration
+	// start is the host time the ignition awaiting its outcome was received,
+	// zero if none. The boiler clock may be off, the timeout is measured on
+	// the host clock.
+	start    time.Time
+	boilerID string
+}
+
+func newFehlzuendungRecord(node *homie.Node, boilerID string) *fehlzuendungRecord {
+	ret := &fehlzuendungRecord{
+		AnzahlFehlzuendungen:         StatusField[int]{Id: "AnzahlFehlzuendungen", Name: MultiLanguageString{EN: "Number of Failed Ignitions", DE: "Anzahl Fehlzündungen"}, Unit: ""},
+		AnzahlErfolgreicheZuendungen: StatusField[int]{Id: "AnzahlErfolgreicheZuendungen", Name: MultiLanguageString{EN: "Number of Successful Ignitions", DE: "Anzahl erfolgreiche Zündungen"}, Unit: ""},
+		Quote:                        StatusField[float64]{Id: "fehlzuendungQuote", Name: MultiLanguageString{EN: "Failed Ignition Rate", DE: "Anteil Fehlzündungen"}, Unit: "%"},
+		LetzteFehlzuendung:           StatusField[string]{Id: "LetzteFehlzuendung", Name: MultiLanguageString{EN: "Last Failed Ignition", DE: "Letzte Fehlzündung"}, Unit: ""},
+		Grund:                        StatusField[string]{Id: "fehlzuendungGrund", Name: MultiLanguageString{EN: "Reason of the Last Failed Ignition", DE: "Grund der letzten Fehlzündung"}, Unit: ""},
+		timeout:                      30 * time.Minute,
+		boilerID:                     boilerID,
+	}
+
+	registerStatusField(&ret.AnzahlFehlzuendungen, node, "kessel", boilerID)
+	registerStatusField(&ret.AnzahlErfolgreicheZuendungen, node, "kessel", boilerID)
+	registerStatusField(&ret.Quote, node, "kessel", boilerID)
+	registerStatusField(&ret.LetzteFehlzuendung, node, "kessel", boilerID)
+	registerStatusField(&ret.Grund, node, "kessel", boilerID)
+
+	return ret
+}
+
+// zuendung starts an ignition received at the host time received
+func (r *fehlzuendungRecord) zuendung(received time.Time) {
+	r.start = received
+}
+
+// erfolgreich counts the running ignition as successful
+func (r *fehlzuendungRecord) erfolgreich() {
+	if r.start.IsZero() {
+		return
+	}
+	r.start = time.Time{}
+	r.AnzahlErfolgreicheZuendungen.SetValue(r.AnzahlErfolgreicheZuendungen.Value + 1)
+	r.updateQuote()
+}
+
+// fehlgeschlagen counts the running ignition as failed for reason at timestamp
+func (r *fehlzuendungRecord) fehlgeschlagen(reason string, timestamp time.Time) {
+	if r.start.IsZero() {
+		return
+	}
+	log.Printf("Ignition of %s started at %s failed: %s", r.boilerID, r.start.Format(time.TimeOnly), reason)
+	r.start = time.Time{}
+	r.AnzahlFehlzuendungen.SetValue(r.AnzahlFehlzuendungen.Value + 1)
+	r.LetzteFehlzuendung.SetValue(timestamp.Format(time.RFC3339))
+	r.Grund.SetValue(reason)
+	fehlzuendungenTotal.WithLabelValues(r.boilerID, reason).Inc()
+	r.updateQuote()
+}
+
+// checkTimeout fails the running ignition if it takes longer than the timeout
+// at the host time now. The failure is recorded at timestamp, now on the
+// boiler clock like the times of the z records. It returns true if the
+// ignition failed.
+func (r *fehlzuendungRecord) checkTimeout(now, timestamp time.Time) bool {
+	if r.start.IsZero() || r.timeout <= 0 || now.Sub(r.start) <= r.timeout {
+		return false
+	}
+	r.fehlgeschlagen(fehlzuendungTimeout, timestamp)
+	return true
+}
+
+func (r *fehlzuendungRecord) updateQuote() {
+	total := r.AnzahlFehlzuendungen.Value + r.AnzahlErfolgreicheZuendungen.Value
+	if total == 0 {
+		return
+	}
+	r.Quote.SetValue(math.Round(float64(r.AnzahlFehlzuendungen.Value)/float64(total)*1000) / 10)
+}
+
+// checkZuendungTimeout fails an ignition which takes too long
+func (b *Boiler) checkZuendungTimeout() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	if b.fehlzuendung.checkTimeout(now, b.uhr.boilerTime(now)) {
+		b.saveState()
+	}
+}
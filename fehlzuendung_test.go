@@ -0,0 +1,120 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestFehlzuendung(t *testing.T) {
+	b := newBoiler("fehlzuendung", "Fehlzuendung")
+	b.now = testClock
+	fehlzuendung := b.fehlzuendung
+
+	for _, line := range []string{
+		// erfolgreich
+		"z 10:00:00 Kessel Zündung",
+		"z 10:08:00 Kessel Leistungsbrand",
+		"z 11:00:00 Kessel Aus",
+		// Aus während der Zündung
+		"z 12:00:00 Kessel Zündung",
+		"z 12:05:00 Kessel Aus",
+		// Zündzeit überschritten
+		"z 13:00:00 Kessel Zündung",
+		"z 13:25:00 Störung Set 10 Stop:1",
+		"z 13:30:00 Störung Quit 0010",
+		"z 13:31:00 Kessel Aus",
+	} {
+		b.processLine(line)
+	}
+
+	if fehlzuendung.AnzahlFehlzuendungen.Value != 2 || fehlzuendung.AnzahlErfolgreicheZuendungen.Value != 1 {
+		t.Fatalf("expected 2 failed and 1 successful ignition, got %d and %d",
+			fehlzuendung.AnzahlFehlzuendungen.Value, fehlzuendung.AnzahlErfolgreicheZuendungen.Value)
+	}
+	if fehlzuendung.Quote.Value != 66.7 {
+		t.Fatalf("expected failed ignition rate 66.7, got %v", fehlzuendung.Quote.Value)
+	}
+	if fehlzuendung.Grund.Value != fehlzuendungStoerung || fehlzuendung.LetzteFehlzuendung.Value != "2026-02-14T13:25:00Z" {
+		t.Fatalf("unexpected last failed ignition %q at %q", fehlzuendung.Grund.Value, fehlzuendung.LetzteFehlzuendung.Value)
+	}
+	for reason, want := range map[string]float64{fehlzuendungAus: 1, fehlzuendungStoerung: 1, fehlzuendungTimeout: 0} {
+		if got := testutil.ToFloat64(fehlzuendungenTotal.WithLabelValues("fehlzuendung", reason)); got != want {
+			t.Errorf("expected %v failed ignitions with reason %s, got %v", want, reason, got)
+		}
+	}
+	if !b.kesselRecord.lastZuendungStart.IsZero() {
+		t.Fatalf("expected no ignition in progress after Aus, got %s", b.kesselRecord.lastZuendungStart)
+	}
+}
+
+func TestFehlzuendungTimeout(t *testing.T) {
+	b := newBoiler("fehlzuendung-timeout", "Fehlzuendung Timeout")
+	b.now = testClock
+	b.fehlzuendung.timeout = 30 * time.Minute
+
+	b.processLine("z 16:00:00 Kessel Zündung")
+	b.now = func() time.Time { return testClock().Add(30 * time.Minute) }
+	b.checkZuendungTimeout()
+	if b.fehlzuendung.AnzahlFehlzuendungen.Value != 0 {
+		t.Fatalf("expected no failed ignition before the timeout")
+	}
+
+	b.now = func() time.Time { return testClock().Add(31 * time.Minute) }
+	b.checkZuendungTimeout()
+	if b.fehlzuendung.AnzahlFehlzuendungen.Value != 1 || b.fehlzuendung.Grund.Value != fehlzuendungTimeout {
+		t.Fatalf("expected a failed ignition by timeout, got %d (%q)", b.fehlzuendung.AnzahlFehlzuendungen.Value, b.fehlzuendung.Grund.Value)
+	}
+
+	// the late Leistungsbrand does not count the ignition a second time
+	b.processLine("z 16:26:00 Kessel Leistungsbrand")
+	if b.fehlzuendung.AnzahlFehlzuendungen.Value != 1 || b.fehlzuendung.AnzahlErfolgreicheZuendungen.Value != 0 {
+		t.Fatalf("expected the ignition to be counted once, got %d failed and %d successful",
+			b.fehlzuendung.AnzahlFehlzuendungen.Value, b.fehlzuendung.AnzahlErfolgreicheZuendungen.Value)
+	}
+	if got := testutil.ToFloat64(fehlzuendungenTotal.WithLabelValues("fehlzuendung-timeout", fehlzuendungTimeout)); got != 1 {
+		t.Fatalf("expected 1 failed ignition by timeout, got %v", got)
+	}
+}
+
+func TestFehlzuendungTimeout_BoilerClockBehind(t *testing.T) {
+	b := newBoiler("fehlzuendung-behind", "Fehlzuendung Behind")
+	b.now = testClock
+	b.fehlzuendung.timeout = 30 * time.Minute
+
+	// the boiler clock is 49 minutes behind the host clock
+	b.processLine("z 15:11:00 Kessel Zündung")
+	b.checkZuendungTimeout()
+	if b.fehlzuendung.AnzahlFehlzuendungen.Value != 0 {
+		t.Fatalf("expected no failed ignition right after the Zündung, got %q", b.fehlzuendung.Grund.Value)
+	}
+
+	b.now = func() time.Time { return testClock().Add(20 * time.Minute) }
+	b.processLine("z 15:31:00 Kessel Leistungsbrand")
+	if b.fehlzuendung.AnzahlFehlzuendungen.Value != 0 || b.fehlzuendung.AnzahlErfolgreicheZuendungen.Value != 1 {
+		t.Fatalf("expected a successful ignition, got %d failed and %d successful",
+			b.fehlzuendung.AnzahlFehlzuendungen.Value, b.fehlzuendung.AnzahlErfolgreicheZuendungen.Value)
+	}
+}
+
+func TestFehlzuendungTimeout_BoilerClockTime(t *testing.T) {
+	b := newBoiler("fehlzuendung-uhr", "Fehlzuendung Uhr")
+	b.now = testClock
+	b.fehlzuendung.timeout = 30 * time.Minute
+
+	// the boiler clock is 49 minutes behind the host clock, the failure is
+	// recorded on the boiler clock like a failure by Aus or Störung
+	b.processLine("z 15:11:00 Kessel Zündung")
+	b.now = func() time.Time { return testClock().Add(31 * time.Minute) }
+	b.checkZuendungTimeout()
+	if b.fehlzuendung.Grund.Value != fehlzuendungTimeout || b.fehlzuendung.LetzteFehlzuendung.Value != "2026-02-14T15:42:00Z" {
+		t.Fatalf("unexpected last failed ignition %q at %q", b.fehlzuendung.Grund.Value, b.fehlzuendung.LetzteFehlzuendung.Value)
+	}
+
+	b.processLine("z 15:50:00 Kessel Zündung")
+	b.processLine("z 15:55:00 Kessel Aus")
+	if b.fehlzuendung.LetzteFehlzuendung.Value != "2026-02-14T15:55:00Z" {
+		t.Fatalf("unexpected last failed ignition at %q", b.fehlzuendung.LetzteFehlzuendung.Value)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/creativeprojects/go-homie"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// kesselZustand is the operating state of the boiler
+type kesselZustand string
+
+const (
+	// zustandUnbekannt is the state until the first Kessel event is received
+	zustandUnbekannt      kesselZustand = "unbekannt"
+	zustandAus            kesselZustand = "aus"
+	zustandZuendung       kesselZustand = "zuendung"
+	zustandLeistungsbrand kesselZustand = "leistungsbrand"
+	zustandEntaschung     kesselZustand = "entaschung"
+	zustandStoerung       kesselZustand = "stoerung"
+)
+
+// kesselZustaende are the values of the Homie enum and the Prometheus state set
+var kesselZustaende = []kesselZustand{
+	zustandUnbekannt, zustandAus, zustandZuendung, zustandLeistungsbrand, zustandEntaschung, zustandStoerung,
+}
+
+// kesselEvents maps the events of the z records "z <time> Kessel <event> [<phase>]" to states
+var kesselEvents = map[string]kesselZustand{
+	"Aus":            zustandAus,
+	"Zündung":        zustandZuendung,
+	"Leistungsbrand": zustandLeistungsbrand,
+	"Entaschung":     zustandEntaschung,
+}
+
+var (
+	kesselZustandGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "hargassner_kessel_zustand",
+		Help: "Betriebszustand des Kessels, 1 für den aktuellen Zustand",
+	}, []string{"boiler", "zustand"})
+	kesselZustandSeitGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "hargassner_kessel_zustand_seit_timestamp_seconds",
+		Help: "Zeitpunkt des Wechsels in den aktuellen Betriebszustand",
+	}, []string{"boiler"})
+)
+
+func init() {
+	prometheus.MustRegister(kesselZustandGauge, kesselZustandSeitGauge)
+}
+
+// registerKesselZustand publishes the state of the boiler as Homie enum
+// property and initializes the Prometheus state set.
+func (r *KesselRecord) registerKesselZustand(node *homie.Node, boilerID string) {
+	values := make([]string, len(kesselZustaende))
+	for i, zustand := range kesselZustaende {
+		values[i] = string(zustand)
+	}
+	r.Zustand.Node = "kessel"
+	r.Zustand.HomieProperty = node.AddProperty(r.Zustand.Id, r.Zustand.Name.EN, homie.TypeEnum).SetFormat(strings.Join(values, ","))
+	registerStatusField(&r.ZustandSeit, node, "kessel", boilerID)
+
+	r.boilerID = boilerID
+	r.kesselZustand = zustandUnbekannt
+	r.publishZustand(zustandUnbekannt)
+}
+
+// handleKesselEvent updates the state with the event of a Kessel z record.
+// Unknown events do not change the state.
+func (r *KesselRecord) handleKesselEvent(event string, now time.Time) {
+	zustand, ok := kesselEvents[event]
+	if !ok {
+		log.Printf("Unknown Kessel event %q", event)
+		return
+	}
+	r.kesselZustand = zustand
+	r.updateZustand(now)
+}
+
+// setStoerung sets the state to Störung while a Störung is active, afterwards
+// the state reported by the last Kessel event applies again.
+func (r *KesselRecord) setStoerung(active bool, now time.Time) {
+	r.stoerung = active
+	r.updateZustand(now)
+}
+
+func (r *KesselRecord) updateZustand(now time.Time) {
+	zustand := r.kesselZustand
+	if r.stoerung {
+		zustand = zustandStoerung
+	}
+	if kesselZustand(r.Zustand.Value) == zustand {
+		return
+	}
+	log.Printf("Kessel %s: %s -> %s", r.boilerID, orDash(r.Zustand.Value), zustand)
+	r.Zustand.SetValue(string(zustand))
+	r.ZustandSeit.SetValue(now.Format(time.RFC3339))
+	kesselZustandSeitGauge.WithLabelValues(r.boilerID).Set(float64(now.Unix()))
+	r.publishZustand(zustand)
+}
+
+// publishZustand sets the Prometheus state set to zustand
+func (r *KesselRecord) publishZustand(zustand kesselZustand) {
+	for _, value := range kesselZustaende {
+		gauge := kesselZustandGauge.WithLabelValues(r.boilerID, string(value))
+		if value == zustand {
+			gauge.Set(1)
+		} else {
+			gauge.Set(0)
+		}
+	}
+}
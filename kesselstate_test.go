@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestKesselZustand(t *testing.T) {
+	b := newBoiler("zustand", "Zustand")
+	b.now = testClock
+	kessel := b.kesselRecord
+	if got := testutil.ToFloat64(kesselZustandGauge.WithLabelValues("zustand", "unbekannt")); got != 1 {
+		t.Fatalf("expected initial state unbekannt, got %v", got)
+	}
+
+	for _, step := range []struct {
+		line string
+		want kesselZustand
+	}{
+		{"z 14:10:40 Kessel Zündung", zustandZuendung},
+		{"z 14:10:40 Kessel Zündung Start", zustandZuendung},
+		{"z 14:17:20 Kessel Zündung Reduziert", zustandZuendung},
+		{"z 14:20:20 Kessel Leistungsbrand", zustandLeistungsbrand},
+		{"z 17:50:18 Kessel Entaschung Start", zustandEntaschung},
+		{"z 17:59:58 Kessel Entaschung Rost", zustandEntaschung},
+		{"z 18:00:32 Kessel Aus", zustandAus},
+		{"z 18:05:00 Kessel Zündung", zustandZuendung},
+		{"z 18:39:41 Störung Set 10 Stop:1", zustandStoerung},
+		{"z 18:40:00 Kessel Aus", zustandStoerung},
+		{"z 18:40:16 Störung Quit 0010", zustandAus},
+	} {
+		b.processLine(step.line)
+		if kesselZustand(kessel.Zustand.Value) != step.want {
+			t.Fatalf("after %q expected state %s, got %s", step.line, step.want, kessel.Zustand.Value)
+		}
+	}
+
+	if kessel.ZustandSeit.Value == "" {
+		t.Fatalf("expected zustandSeit")
+	}
+	if got := testutil.ToFloat64(kesselZustandGauge.WithLabelValues("zustand", "aus")); got != 1 {
+		t.Fatalf("expected state set aus=1, got %v", got)
+	}
+	if got := testutil.ToFloat64(kesselZustandGauge.WithLabelValues("zustand", "stoerung")); got != 0 {
+		t.Fatalf("expected state set stoerung=0, got %v", got)
+	}
+	if property := b.device.Node("kessel").Property("zustand"); property == nil || property.DataType() != "enum" {
+		t.Fatalf("expected Homie enum property zustand, got %+v", property)
+	}
+}
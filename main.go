@@ -1,8 +1,8 @@
 package main
 
 import (
-	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -10,6 +10,8 @@ import (
 	"os/signal"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -17,42 +19,34 @@ import (
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
-	"go.bug.st/serial"
 )
 
 var mqttClient mqtt.Client
-var homieDevice = homie.NewDevice("hargassner", "Hargassner Heizung")
-var nodeProcessWerte = homieDevice.AddNode("prozesswerte", "Prozesswerte", "Prozesswerte")
-var nodeHeizkreis1 = homieDevice.AddNode("heizkreis1", "Heizkreis 1", "Heizkreis 1")
-var nodeHeizkreis2 = homieDevice.AddNode("heizkreis2", "Heizkreis 2", "Heizkreis 2")
-var nodeStoerung = homieDevice.AddNode("stoerung", "Störung", "Störung")
-var nodeKessel = homieDevice.AddNode("kessel", "Kessel", "Kessel")
-
-var stoerungRecord = newEmptyStoerungRecord(nodeStoerung)
-var kesselRecord = newEmptyKesselRecord(nodeKessel)
-
-var meldung = newMeldung(nodeProcessWerte)
 
 type MultiLanguageString struct {
-	EN string
-	DE string
+	EN string `json:"en" yaml:"en"`
+	DE string `json:"de" yaml:"de"`
 }
 
-func newMeldung(node *homie.Node) StatusField[string] {
+func newMeldung(node *homie.Node, boilerID string) StatusField[string] {
 	ret := StatusField[string]{
 		Id:   "meldung",
 		Name: MultiLanguageString{EN: "Message", DE: "Meldung"},
 		Unit: "",
 	}
-	registerStatusField(&ret, node, "prozesswerte")
+	registerStatusField(&ret, node, "prozesswerte", boilerID)
 	return ret
 }
 
 type StatusField[T any] struct {
-	Id            string
-	Value         T
-	Name          MultiLanguageString
-	Unit          string
+	Id    string
+	Value T
+	Name  MultiLanguageString
+	Unit  string
+	// Node is the ID of the Homie node the field is published on
+	Node string
+	// Updated is the time the value was received, zero if no value was received yet
+	Updated       time.Time
 	HomieProperty *homie.Property
 	PromGauge     prometheus.Gauge
 }
@@ -69,6 +63,10 @@ type StatusRecord struct {
 	FlowTemperatureCircuit2    StatusField[float64]
 	FlowTemperatureCircuit1Set StatusField[float64]
 	FlowTemperatureCircuit2Set StatusField[float64]
+	FlowTemperatureCircuit3    StatusField[float64]
+	FlowTemperatureCircuit4    StatusField[float64]
+	FlowTemperatureCircuit3Set StatusField[float64]
+	FlowTemperatureCircuit4Set StatusField[float64]
 	ReturnBoiler2BufferTemp    StatusField[int]
 	BoilerTemperature1         StatusField[int]
 	FeedRate                   StatusField[int]
@@ -79,9 +77,101 @@ type StatusRecord struct {
 	BoilerTemperature2SM       StatusField[float64]
 	HK1FR25                    StatusField[float64]
 	HK2FR25                    StatusField[float64]
+	HK3FR25                    StatusField[float64]
+	HK4FR25                    StatusField[float64]
 	MotorCurrentFeedScrew      StatusField[float64]
 	MotorCurrentAshDischarge   StatusField[float64]
 	MotorCurrentRoomDischarge  StatusField[float64]
+
+	// Other holds the fields of the pm profile without counterpart above
+	Other []pmField
+
+	// profile maps the fields of the pm records, nil until a profile is selected
+	profile *pmProfile
+	bound   []pmField
+	// checks holds the plausibility check of each bound field, nil if unchecked
+	checks []*plausibilityCheck
+	// received holds the fields set by the last pm record
+	received map[pmField]bool
+}
+
+// pmField is a status field which is filled from a field of the pm record
+type pmField interface {
+	parse(value string) error
+	propertyType() homie.PropertyType
+	describe(mapping pmFieldMapping)
+	register(node *homie.Node, nodeName string, boilerID string)
+	status(now time.Time) StatusValue
+}
+
+// knownFields returns the fields of the record the monitor knows, keyed by
+// "<node>/<id>" of the HSV profile.
+func (r *StatusRecord) knownFields() map[string]pmField {
+	return map[string]pmField{
+		"prozesswerte/primaerLuftGeblaese":       &r.PrimaryAirFan,
+		"prozesswerte/saugluftGeblaese":          &r.ExhaustFan,
+		"prozesswerte/o2InAbgas":                 &r.O2InExhaustGas,
+		"prozesswerte/kesselTemperatur":          &r.BoilerTemperature,
+		"prozesswerte/rauchgasTemperatur":        &r.ExhaustGasTemperature,
+		"prozesswerte/aussenTemperaturAktuell":   &r.CurrentOutdoorTemperature,
+		"prozesswerte/aussenTemperaturGemittelt": &r.AverageOutdoorTemperature,
+		"heizkreis1/vorlaufTemperatur":           &r.FlowTemperatureCircuit1,
+		"heizkreis2/vorlaufTemperatur":           &r.FlowTemperatureCircuit2,
+		"heizkreis1/vorlaufSollTemperatur":       &r.FlowTemperatureCircuit1Set,
+		"heizkreis2/vorlaufSollTemperatur":       &r.FlowTemperatureCircuit2Set,
+		"heizkreis3/vorlaufTemperatur":           &r.FlowTemperatureCircuit3,
+		"heizkreis4/vorlaufTemperatur":           &r.FlowTemperatureCircuit4,
+		"heizkreis3/vorlaufSollTemperatur":       &r.FlowTemperatureCircuit3Set,
+		"heizkreis4/vorlaufSollTemperatur":       &r.FlowTemperatureCircuit4Set,
+		"prozesswerte/ruecklaufBoiler2":          &r.ReturnBoiler2BufferTemp,
+		"prozesswerte/boiler1Temperatur":         &r.BoilerTemperature1,
+		"prozesswerte/foerderMenge":              &r.FeedRate,
+		"prozesswerte/boiler1SollTemperatur":     &r.BoilerSetTemperature,
+		"prozesswerte/unterdruckAktuell":         &r.CurrentUnderpressure,
+		"prozesswerte/unterdruckGemittelt":       &r.AverageUnderpressure,
+		"prozesswerte/unterdruckSoll":            &r.SetUnderpressure,
+		"prozesswerte/BoilerTemperature2SM":      &r.BoilerTemperature2SM,
+		"heizkreis1/HK1FR25":                     &r.HK1FR25,
+		"heizkreis2/HK2FR25":                     &r.HK2FR25,
+		"heizkreis3/HK3FR25":                     &r.HK3FR25,
+		"heizkreis4/HK4FR25":                     &r.HK4FR25,
+		"prozesswerte/stromEinschub":             &r.MotorCurrentFeedScrew,
+		"prozesswerte/stromAscheaustragung":      &r.MotorCurrentAshDischarge,
+		"prozesswerte/stromRaumaustragung":       &r.MotorCurrentRoomDischarge,
+	}
+}
+
+// bind maps the fields of the pm records to the status fields according to
+// profile. It returns the bound fields in the order of the profile fields.
+func (r *StatusRecord) bind(profile *pmProfile) ([]pmField, error) {
+	known := r.knownFields()
+	var bound, other []pmField
+	for _, mapping := range profile.Fields {
+		field, ok := known[mapping.Node+"/"+mapping.Id]
+		if ok {
+			if field.propertyType() != homie.PropertyType(mapping.Type) {
+				return nil, fmt.Errorf("field %s/%s of pm profile %s must have type %s", mapping.Node, mapping.Id, profile.Name, field.propertyType())
+			}
+		} else {
+			switch homie.PropertyType(mapping.Type) {
+			case homie.TypeInteger:
+				field = &StatusField[int]{}
+			case homie.TypeString:
+				field = &StatusField[string]{}
+			default:
+				field = &StatusField[float64]{}
+			}
+			other = append(other, field)
+		}
+		bound = append(bound, field)
+	}
+	for i, field := range bound {
+		field.describe(profile.Fields[i])
+	}
+	r.Other = other
+	r.profile = profile
+	r.bound = bound
+	return bound, nil
 }
 
 type StoerungRecord struct {
@@ -95,25 +185,54 @@ type KesselRecord struct {
 	DauerLetzteZuendung        StatusField[int]
 	DauerLetzterLeistungsbrand StatusField[int]
 	AnzahlZuendungen           StatusField[int]
-	lastZuendungStart          time.Time
-	lastLeistungsbrandStart    time.Time
+	// LetzteZuendung and LetzterLeistungsbrand are the start times in RFC 3339 format
+	LetzteZuendung        StatusField[string]
+	LetzterLeistungsbrand StatusField[string]
+	// Zustand is the operating state, ZustandSeit the time it was entered
+	Zustand     StatusField[string]
+	ZustandSeit StatusField[string]
+	// DauerZuendungPhase holds the durations of the sub phases of the last ignition
+	DauerZuendungPhase      map[string]*StatusField[int]
+	lastZuendungStart       time.Time
+	lastLeistungsbrandStart time.Time
+
+	// zuendungPhase is the current sub phase of the ignition started at
+	// zuendungPhaseStart, zuendungPhasenDauer sums the durations of the
+	// phases and is nil outside of an ignition.
+	zuendungPhase       string
+	zuendungPhaseStart  time.Time
+	zuendungPhasenDauer map[string]time.Duration
+
+	boilerID string
+	// kesselZustand is the state reported by the last Kessel event
+	kesselZustand kesselZustand
+	// stoerung is true while a Störung is active
+	stoerung bool
 }
 
-func newEmptyKesselRecord(node *homie.Node) *KesselRecord {
+func newEmptyKesselRecord(node *homie.Node, boilerID string) *KesselRecord {
 	ret := &KesselRecord{
 		DauerLetzteZuendung:        StatusField[int]{Id: "DauerLetzteZuendung", Name: MultiLanguageString{EN: "Duration Last Ignition", DE: "Dauer letzte Zündung"}, Unit: "s"},
 		DauerLetzterLeistungsbrand: StatusField[int]{Id: "DauerLetzterLeistungsbrand", Name: MultiLanguageString{EN: "Duration Last Power Fire", DE: "Dauer letzter Leistungsbrand"}, Unit: "s"},
 		AnzahlZuendungen:           StatusField[int]{Id: "AnzahlZuendungen", Name: MultiLanguageString{EN: "Number of Ignitions", DE: "Anzahl Zündungen"}, Unit: ""},
+		LetzteZuendung:             StatusField[string]{Id: "LetzteZuendung", Name: MultiLanguageString{EN: "Last Ignition", DE: "Letzte Zündung"}, Unit: ""},
+		LetzterLeistungsbrand:      StatusField[string]{Id: "LetzterLeistungsbrand", Name: MultiLanguageString{EN: "Last Power Fire", DE: "Letzter Leistungsbrand"}, Unit: ""},
+		Zustand:                    StatusField[string]{Id: "zustand", Name: MultiLanguageString{EN: "Operating State", DE: "Betriebszustand"}, Unit: ""},
+		ZustandSeit:                StatusField[string]{Id: "zustandSeit", Name: MultiLanguageString{EN: "Operating State Since", DE: "Betriebszustand seit"}, Unit: ""},
 	}
 
-	registerStatusField(&ret.DauerLetzteZuendung, node, "kessel")
-	registerStatusField(&ret.DauerLetzterLeistungsbrand, node, "kessel")
-	registerStatusField(&ret.AnzahlZuendungen, node, "kessel")
+	registerStatusField(&ret.DauerLetzteZuendung, node, "kessel", boilerID)
+	registerStatusField(&ret.DauerLetzterLeistungsbrand, node, "kessel", boilerID)
+	registerStatusField(&ret.AnzahlZuendungen, node, "kessel", boilerID)
+	registerStatusField(&ret.LetzteZuendung, node, "kessel", boilerID)
+	registerStatusField(&ret.LetzterLeistungsbrand, node, "kessel", boilerID)
+	ret.registerKesselZustand(node, boilerID)
+	ret.registerZuendungPhasen(node, boilerID)
 
 	return ret
 }
 
-func newEmptyStoerungRecord(node *homie.Node) *StoerungRecord {
+func newEmptyStoerungRecord(node *homie.Node, boilerID string) *StoerungRecord {
 	ret := &StoerungRecord{
 		StoerungNr:     StatusField[int]{Id: "nr", Name: MultiLanguageString{EN: "Error Number", DE: "Störungsnummer"}, Unit: ""},
 		StoerungText:   StatusField[string]{Id: "text", Name: MultiLanguageString{EN: "Error Text", DE: "Störungstext"}, Unit: ""},
@@ -121,16 +240,17 @@ func newEmptyStoerungRecord(node *homie.Node) *StoerungRecord {
 		LastActive:     StatusField[string]{Id: "lastActive", Name: MultiLanguageString{EN: "Last Active", DE: "Letzte Aktivität"}, Unit: ""},
 	}
 
-	registerStatusField(&ret.StoerungNr, node, "stoerung")
-	registerStatusField(&ret.StoerungText, node, "stoerung")
-	registerStatusField(&ret.StoerungActive, node, "stoerung")
-	registerStatusField(&ret.LastActive, node, "stoerung")
+	registerStatusField(&ret.StoerungNr, node, "stoerung", boilerID)
+	registerStatusField(&ret.StoerungText, node, "stoerung", boilerID)
+	registerStatusField(&ret.StoerungActive, node, "stoerung", boilerID)
+	registerStatusField(&ret.LastActive, node, "stoerung", boilerID)
 
 	return ret
 }
 
 func (field *StatusField[T]) SetValue(value T) {
 	field.Value = value
+	field.Updated = time.Now()
 	if field.HomieProperty != nil {
 		field.HomieProperty.Set(value)
 	}
@@ -163,6 +283,10 @@ func newEmptyStatusRecord() *StatusRecord {
 		FlowTemperatureCircuit2:    StatusField[float64]{Id: "vorlaufTemperatur", Name: MultiLanguageString{EN: "Flow Temperature Circuit 2", DE: "Vorlauftemperatur Kreis 2"}, Unit: "°C"},
 		FlowTemperatureCircuit1Set: StatusField[float64]{Id: "vorlaufSollTemperatur", Name: MultiLanguageString{EN: "Flow Temperature Circuit 1 Set", DE: "Soll-Vorlauftemperatur Kreis 1"}, Unit: "°C"},
 		FlowTemperatureCircuit2Set: StatusField[float64]{Id: "vorlaufSollTemperatur", Name: MultiLanguageString{EN: "Flow Temperature Circuit 2 Set", DE: "Soll-Vorlauftemperatur Kreis 2"}, Unit: "°C"},
+		FlowTemperatureCircuit3:    StatusField[float64]{Id: "vorlaufTemperatur", Name: MultiLanguageString{EN: "Flow Temperature Circuit 3", DE: "Vorlauftemperatur Kreis 3"}, Unit: "°C"},
+		FlowTemperatureCircuit4:    StatusField[float64]{Id: "vorlaufTemperatur", Name: MultiLanguageString{EN: "Flow Temperature Circuit 4", DE: "Vorlauftemperatur Kreis 4"}, Unit: "°C"},
+		FlowTemperatureCircuit3Set: StatusField[float64]{Id: "vorlaufSollTemperatur", Name: MultiLanguageString{EN: "Flow Temperature Circuit 3 Set", DE: "Soll-Vorlauftemperatur Kreis 3"}, Unit: "°C"},
+		FlowTemperatureCircuit4Set: StatusField[float64]{Id: "vorlaufSollTemperatur", Name: MultiLanguageString{EN: "Flow Temperature Circuit 4 Set", DE: "Soll-Vorlauftemperatur Kreis 4"}, Unit: "°C"},
 		ReturnBoiler2BufferTemp:    StatusField[int]{Id: "ruecklaufBoiler2", Name: MultiLanguageString{EN: "Return Boiler to Buffer Temperature", DE: "Rücklauftemperatur Boiler2"}, Unit: "°C"},
 		BoilerTemperature1:         StatusField[int]{Id: "boiler1Temperatur", Name: MultiLanguageString{EN: "Boiler Temperature 1", DE: "Kesseltemperatur 1"}, Unit: "°C"},
 		FeedRate:                   StatusField[int]{Id: "foerderMenge", Name: MultiLanguageString{EN: "Feed Rate", DE: "Fördermenge"}, Unit: "%"},
@@ -173,6 +297,8 @@ func newEmptyStatusRecord() *StatusRecord {
 		BoilerTemperature2SM:       StatusField[float64]{Id: "BoilerTemperature2SM", Name: MultiLanguageString{EN: "Boiler Temperature 2", DE: "Boilertemperatur 2"}, Unit: "°C"},
 		HK1FR25:                    StatusField[float64]{Id: "HK1FR25", Name: MultiLanguageString{EN: "HK1 FR25", DE: "HK1 FR25"}, Unit: "°C"},
 		HK2FR25:                    StatusField[float64]{Id: "HK2FR25", Name: MultiLanguageString{EN: "HK2 FR25", DE: "HK2 FR25"}, Unit: "°C"},
+		HK3FR25:                    StatusField[float64]{Id: "HK3FR25", Name: MultiLanguageString{EN: "HK3 FR25", DE: "HK3 FR25"}, Unit: "°C"},
+		HK4FR25:                    StatusField[float64]{Id: "HK4FR25", Name: MultiLanguageString{EN: "HK4 FR25", DE: "HK4 FR25"}, Unit: "°C"},
 		MotorCurrentFeedScrew:      StatusField[float64]{Id: "stromEinschub", Name: MultiLanguageString{EN: "Motor Current Feed Screw", DE: "Strom Einschub"}, Unit: "A"},
 		MotorCurrentAshDischarge:   StatusField[float64]{Id: "stromAscheaustragung", Name: MultiLanguageString{EN: "Motor Current Ash Discharge", DE: "Strom Ascheaustragung"}, Unit: "A"},
 		MotorCurrentRoomDischarge:  StatusField[float64]{Id: "stromRaumaustragung", Name: MultiLanguageString{EN: "Motor Current Room Discharge", DE: "Strom Raumaustragung"}, Unit: "A"},
@@ -180,76 +306,111 @@ func newEmptyStatusRecord() *StatusRecord {
 
 }
 
-func readinessProbe(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Service is ready"))
+// readinessProbe reports the service as ready while all boilers send pm records.
+func readinessProbe(boilers []*Boiler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		for _, b := range boilers {
+			if b.watchdog.Stalled() {
+				http.Error(w, fmt.Sprintf("No pm record received from %s for %s", b.ID, b.watchdog.Age("pm").Round(time.Second)), http.StatusServiceUnavailable)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Service is ready"))
+	}
 }
 
-func parseField[T any](fields []string, index int, field StatusField[T]) {
-	if index >= len(fields) {
-		log.Fatalf("index %d out of range for fields", index)
-	}
-	value := fields[index]
+// parse sets the value of the field from a field of a pm record or a
+// published value
+func (field *StatusField[T]) parse(value string) error {
 	var fieldValue T
 	switch any(field.Value).(type) {
 	case int:
 		parsedValue, err := strconv.Atoi(value)
 		if err != nil {
-			log.Printf("invalid int field[%d] [%s]: %v (fields: %s)", index, field.Id, err, strings.Join(fields, "|"))
-			return
+			return err
 		}
 		fieldValue = any(parsedValue).(T)
 	case float64:
 		parsedValue, err := strconv.ParseFloat(value, 64)
 		if err != nil {
-			log.Printf("invalid float field[%d] [%s]: %v (fields: %s)", index, field.Id, err, strings.Join(fields, "|"))
-			return
+			return err
+		}
+		fieldValue = any(parsedValue).(T)
+	case bool:
+		parsedValue, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
 		}
 		fieldValue = any(parsedValue).(T)
 	case string:
 		fieldValue = any(value).(T)
 	default:
-		log.Fatalf("unsupported consumer type for field %s at %d", field.Id, index)
+		return fmt.Errorf("unsupported type of field %s", field.Id)
 	}
 	field.SetValue(fieldValue)
+	return nil
+}
+
+func (field *StatusField[T]) propertyType() homie.PropertyType {
+	switch any(field.Value).(type) {
+	case int:
+		return homie.TypeInteger
+	case float64:
+		return homie.TypeFloat
+	case bool:
+		return homie.TypeBoolean
+	case string:
+		return homie.TypeString
+	default:
+		return ""
+	}
 }
 
-func parseStatusRecord(fields []string, record *StatusRecord) error {
-	if len(fields) < 32 {
-		return fmt.Errorf("not enough fields")
-	}
-
-	parseField(fields, 1, record.PrimaryAirFan)
-	parseField(fields, 2, record.ExhaustFan)
-	parseField(fields, 3, record.O2InExhaustGas)
-	parseField(fields, 4, record.BoilerTemperature)
-	parseField(fields, 5, record.ExhaustGasTemperature)
-	parseField(fields, 6, record.CurrentOutdoorTemperature)
-	parseField(fields, 7, record.AverageOutdoorTemperature)
-	parseField(fields, 8, record.FlowTemperatureCircuit1)
-	parseField(fields, 9, record.FlowTemperatureCircuit2)
-	parseField(fields, 10, record.FlowTemperatureCircuit1Set)
-	parseField(fields, 11, record.FlowTemperatureCircuit2Set)
-	parseField(fields, 12, record.ReturnBoiler2BufferTemp)
-	parseField(fields, 13, record.BoilerTemperature1)
-	parseField(fields, 14, record.FeedRate)
-	parseField(fields, 15, record.BoilerSetTemperature)
-	parseField(fields, 16, record.CurrentUnderpressure)
-	parseField(fields, 17, record.AverageUnderpressure)
-	parseField(fields, 18, record.SetUnderpressure)
-	// Field 19 to 23 are for Heizkreis 3 and 4
-	parseField(fields, 23, record.BoilerTemperature2SM)
-	parseField(fields, 24, record.HK1FR25)
-	parseField(fields, 25, record.HK2FR25)
-
-	// Field 26 and 27 for Heizkreis 3 and 4
-
-	// 28, 29, 30, 31 are not used
-	parseField(fields, 29, record.MotorCurrentFeedScrew)
-	parseField(fields, 30, record.MotorCurrentAshDischarge)
-	parseField(fields, 31, record.MotorCurrentRoomDischarge)
+// describe takes the ID, names and unit of the field from a pm profile
+func (field *StatusField[T]) describe(mapping pmFieldMapping) {
+	field.Id = mapping.Id
+	field.Name = mapping.Name
+	field.Unit = mapping.Unit
+}
 
-	return nil
+func (field *StatusField[T]) register(node *homie.Node, nodeName string, boilerID string) {
+	registerStatusField(field, node, nodeName, boilerID)
+}
+
+// parseStatusRecord sets the fields of record from a pm record received at
+// received. Malformed fields are skipped, the returned error lists all of them.
+func parseStatusRecord(fields []string, record *StatusRecord, received time.Time) error {
+	clear(record.received)
+	if record.profile == nil {
+		return newParseError("pm", "", "no pm profile for %d fields", len(fields))
+	}
+	if len(fields) < record.profile.FieldCount {
+		return newParseError("pm", "", "not enough fields: got %d, pm profile %s expects %d", len(fields), record.profile.Name, record.profile.FieldCount)
+	}
+
+	var errs []error
+
+	for i, field := range record.bound {
+		index := record.profile.Fields[i].Index
+		if i < len(record.checks) && record.checks[i] != nil {
+			value, err := strconv.ParseFloat(fields[index], 64)
+			if err == nil && !record.checks[i].accept(value, received) {
+				continue
+			}
+		}
+		if err := field.parse(fields[index]); err != nil {
+			mapping := record.profile.Fields[i]
+			errs = append(errs, newParseError("pm", mapping.Node+"/"+mapping.Id, "field %d: %w", index, err))
+			continue
+		}
+		if record.received == nil {
+			record.received = make(map[pmField]bool)
+		}
+		record.received[field] = true
+	}
+
+	return errors.Join(errs...)
 }
 
 func getEnv(name string, defaultValue string) string {
@@ -260,8 +421,24 @@ func getEnv(name string, defaultValue string) string {
 	return value
 }
 
+// getEnvDuration reads a duration like "30s" or "5m" from the environment.
+// Invalid values are logged and replaced by the default.
+func getEnvDuration(name string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("invalid duration in %s (%s): %v, using %s", name, value, err, defaultValue)
+		return defaultValue
+	}
+	return duration
+}
+
 // topicToValue is a map of topics to values to avoid sending the same value multiple times
 var topicToValue = make(map[string]string)
+var topicToValueMu sync.Mutex
 
 // onSet handles the setting of a topic's value and publishes the value if it has changed.
 // It ensures that blank strings are not sent for non-string data types.
@@ -284,33 +461,47 @@ func onSet(topic, value string, dataType homie.PropertyType) {
 		return
 	}
 	// Publish the values only when it really changes
-	if topicToValue[topic] != value {
-		topicToValue[topic] = value
+	topicToValueMu.Lock()
+	changed := topicToValue[topic] != value
+	topicToValue[topic] = value
+	topicToValueMu.Unlock()
+	if changed {
 		publish(topic, value)
 	}
 }
 
+// retainTopics holds the topics of the values retained by the broker: the
+// persisted Kessel and Störung fields and the statistics. It is filled before
+// connecting to the broker. Process values are not retained.
+var retainTopics = make(map[string]bool)
+
+// publish sends a property value
 func publish(topic, value string) {
-	mqttClient.Publish(topic, 0, false, value)
+	mqttClient.Publish(topic, 0, retainTopics[topic], value)
 }
 
 func onConnectionLost(client mqtt.Client, err error) {
 	log.Printf("MQTT connection lost: %v", err)
+	topicToValueMu.Lock()
 	for k := range topicToValue {
 		delete(topicToValue, k)
 	}
+	topicToValueMu.Unlock()
 }
 
-func onConnected(client mqtt.Client) {
-	log.Printf("Connected to MQTT broker")
-	publishAllHomieAttributes()
-	homieDevice.SetState(homie.StateReady)
-}
+// startedUp is set once the state is restored and the Homie attributes are
+// published. Until then a connect publishes nothing, the restore from MQTT
+// must see the retained values before they are overwritten.
+var startedUp atomic.Bool
 
-func publishAllHomieAttributes() {
-	// get the full homie definition to send to MQTT - you only need to send it once unless it's changing over time
-	for _, attribute := range homieDevice.GetHomieAttributes() {
-		mqttClient.Publish(attribute.Topic, 0, true, attribute.Value)
+func onConnected(boilers []*Boiler) {
+	log.Printf("Connected to MQTT broker")
+	if !startedUp.Load() {
+		return
+	}
+	for _, b := range boilers {
+		b.publishHomieAttributes()
+		b.updateHomieState()
 	}
 }
 
@@ -345,34 +536,55 @@ func getStoerungText(stoerNr int) string {
 	return text
 }
 
-func registerStatusField[T any](field *StatusField[T], node *homie.Node, nodeName string) {
+// promGaugeVecs holds the gauge vectors shared by the fields of all boilers
+var promGaugeVecs = make(map[string]*prometheus.GaugeVec)
+var promGaugeVecsMu sync.Mutex
+
+// boilerGaugeVec returns the registered gauge vector with the label "boiler" for name.
+func boilerGaugeVec(name, help string) (*prometheus.GaugeVec, error) {
+	promGaugeVecsMu.Lock()
+	defer promGaugeVecsMu.Unlock()
+	if vec, ok := promGaugeVecs[name]; ok {
+		return vec, nil
+	}
+	vec := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: name, Help: help}, []string{"boiler"})
+	if err := prometheus.Register(vec); err != nil {
+		return nil, err
+	}
+	promGaugeVecs[name] = vec
+	return vec, nil
+}
 
-	var propertyType homie.PropertyType
+// deleteBoilerGauge removes the series of the boiler from the gauge vector name
+func deleteBoilerGauge(name, boilerID string) {
+	promGaugeVecsMu.Lock()
+	defer promGaugeVecsMu.Unlock()
+	if vec, ok := promGaugeVecs[name]; ok {
+		vec.DeleteLabelValues(boilerID)
+	}
+}
 
-	switch any(field.Value).(type) {
-	case int:
-		propertyType = homie.TypeInteger
-	case float64:
-		propertyType = homie.TypeFloat
-	case bool:
-		propertyType = homie.TypeBoolean
-	case string:
-		propertyType = homie.TypeString
-	default:
+// statusGaugeName returns the name of the Prometheus gauge of a status field
+func statusGaugeName(nodeName, id string) string {
+	return "hargassner_" + nodeName + "_" + strings.ReplaceAll(id, "-", "_")
+}
+
+func registerStatusField[T any](field *StatusField[T], node *homie.Node, nodeName string, boilerID string) {
+
+	propertyType := field.propertyType()
+	if propertyType == "" {
 		log.Fatalf("unsupported type of field %s", field.Id)
 	}
+	field.Node = nodeName
 	field.HomieProperty = node.AddProperty(field.Id, field.Name.EN, propertyType).SetUnit(field.Unit)
 
 	if propertyType != homie.TypeString {
-		name := "hargassner_" + nodeName + "_" + strings.ReplaceAll(field.Id, "-", "_")
-		gauge := prometheus.NewGauge(prometheus.GaugeOpts{
-			Name: name,
-			Help: field.Name.DE,
-		})
-		if err := prometheus.Register(gauge); err != nil {
+		name := statusGaugeName(nodeName, field.Id)
+		vec, err := boilerGaugeVec(name, field.Name.DE)
+		if err != nil {
 			log.Printf("could not register prometheus gauge for %s (%s): %v", field.Id, name, err)
 		} else {
-			field.PromGauge = gauge
+			field.PromGauge = vec.WithLabelValues(boilerID)
 		}
 	}
 }
@@ -385,67 +597,89 @@ var (
 
 func main() {
 
-	if len(os.Args) > 1 && os.Args[1] == "version" {
-		fmt.Printf("Version: %s (build %s, commit %s) \n", version, build, commit)
-		return
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "version":
+			fmt.Printf("Version: %s (build %s, commit %s) \n", version, build, commit)
+			return
+		case "replay":
+			runReplay(os.Args[2:])
+			return
+		case "simulate":
+			runSimulate(os.Args[2:])
+			return
+		case "analyze":
+			runAnalyze(os.Args[2:])
+			return
+		}
 	}
 
 	log.Printf("Starting hargassner-monitor version %s (build %s, commit %s)", version, build, commit)
 
-	serialDevice := getEnv("HARGASSNER_SERIAL_DEVICE", "/dev/ttyUSB0")
+	var state *stateFile
+	if path := getEnv("HARGASSNER_STATE_FILE", ""); path != "" {
+		var err error
+		state, err = loadStateFile(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
 
-	mode := &serial.Mode{
-		BaudRate: 19200,
-		Parity:   serial.NoParity,
-		DataBits: 8,
-		StopBits: serial.OneStopBit,
+	ids := configuredBoilerIDs()
+	var boilers []*Boiler
+	for _, id := range ids {
+		b, err := newConfiguredBoiler(id, len(ids) > 1)
+		if err != nil {
+			log.Fatal(err)
+		}
+		b.reader, err = newBoilerReader(b)
+		if err != nil {
+			log.Fatal(err)
+		}
+		b.state = state
+		boilers = append(boilers, b)
 	}
 
-	port, err := serial.Open(serialDevice, mode)
-	if err != nil {
-		log.Fatalf("could not open %s: %s", serialDevice, err)
-	}
-
-	statusRecord := newEmptyStatusRecord()
-
-	registerStatusField(&statusRecord.PrimaryAirFan, nodeProcessWerte, "prozesswerte")
-	registerStatusField(&statusRecord.ExhaustFan, nodeProcessWerte, "prozesswerte")
-	registerStatusField(&statusRecord.O2InExhaustGas, nodeProcessWerte, "prozesswerte")
-	registerStatusField(&statusRecord.BoilerTemperature, nodeProcessWerte, "prozesswerte")
-	registerStatusField(&statusRecord.ExhaustGasTemperature, nodeProcessWerte, "prozesswerte")
-	registerStatusField(&statusRecord.CurrentOutdoorTemperature, nodeProcessWerte, "prozesswerte")
-	registerStatusField(&statusRecord.AverageOutdoorTemperature, nodeProcessWerte, "prozesswerte")
-	registerStatusField(&statusRecord.FlowTemperatureCircuit1, nodeHeizkreis1, "heizkreis1")
-	registerStatusField(&statusRecord.FlowTemperatureCircuit2, nodeHeizkreis2, "heizkreis2")
-	registerStatusField(&statusRecord.FlowTemperatureCircuit1Set, nodeHeizkreis1, "heizkreis1")
-	registerStatusField(&statusRecord.FlowTemperatureCircuit2Set, nodeHeizkreis2, "heizkreis2")
-	registerStatusField(&statusRecord.ReturnBoiler2BufferTemp, nodeProcessWerte, "prozesswerte")
-	registerStatusField(&statusRecord.BoilerTemperature1, nodeProcessWerte, "prozesswerte")
-	registerStatusField(&statusRecord.FeedRate, nodeProcessWerte, "prozesswerte")
-	registerStatusField(&statusRecord.BoilerSetTemperature, nodeProcessWerte, "prozesswerte")
-	registerStatusField(&statusRecord.CurrentUnderpressure, nodeProcessWerte, "prozesswerte")
-	registerStatusField(&statusRecord.AverageUnderpressure, nodeProcessWerte, "prozesswerte")
-	registerStatusField(&statusRecord.SetUnderpressure, nodeProcessWerte, "prozesswerte")
-	registerStatusField(&statusRecord.BoilerTemperature2SM, nodeProcessWerte, "prozesswerte")
-	registerStatusField(&statusRecord.HK1FR25, nodeHeizkreis1, "heizkreis1")
-	registerStatusField(&statusRecord.HK2FR25, nodeHeizkreis2, "heizkreis2")
-	registerStatusField(&statusRecord.MotorCurrentFeedScrew, nodeProcessWerte, "prozesswerte")
-	registerStatusField(&statusRecord.MotorCurrentAshDischarge, nodeProcessWerte, "prozesswerte")
-	registerStatusField(&statusRecord.MotorCurrentRoomDischarge, nodeProcessWerte, "prozesswerte")
-
-	homieDevice.OnSet(onSet)
+	runMonitor(boilers)
+}
+
+// runMonitor publishes the records delivered by the readers of the boilers via
+// MQTT, Prometheus and HTTP until all readers finish or the process is asked to
+// shut down.
+func runMonitor(boilers []*Boiler) {
+	for _, b := range boilers {
+		b.device.OnSet(onSet)
+		for _, collector := range b.watchdog.Collectors(b.ID) {
+			prometheus.MustRegister(collector)
+		}
+	}
 
 	httpPort := getEnv("HARGASSNER_MONITOR_PORT", "8080")
 
 	log.Printf("HTTP service is listening on port %s", httpPort)
 
 	readinessEndpoint := "/readiness"
-	http.HandleFunc(readinessEndpoint, readinessProbe)
+	http.HandleFunc(readinessEndpoint, readinessProbe(boilers))
 	log.Printf("Readiness endpoint is %s", readinessEndpoint)
 
+	// /stoerung addresses the first boiler, /stoerung/{boiler} the boiler with that ID
 	stoerungEndpoint := "/stoerung"
+	handleStoerung := boilerHandler(boilers, (*Boiler).handleStoerung)
 	http.HandleFunc(stoerungEndpoint, handleStoerung)
+	http.HandleFunc(stoerungEndpoint+"/{boiler}", handleStoerung)
 	log.Printf("Stoerung endpoint is %s", stoerungEndpoint)
+
+	statusEndpoint := "/status"
+	handleStatus := boilerHandler(boilers, (*Boiler).handleStatus)
+	http.HandleFunc(statusEndpoint, handleStatus)
+	http.HandleFunc(statusEndpoint+"/{boiler}", handleStatus)
+	log.Printf("Status endpoint is %s", statusEndpoint)
+
+	cyclesEndpoint := "/cycles"
+	handleCycles := boilerHandler(boilers, (*Boiler).handleCycles)
+	http.HandleFunc(cyclesEndpoint, handleCycles)
+	http.HandleFunc(cyclesEndpoint+"/{boiler}", handleCycles)
+	log.Printf("Cycles endpoint is %s", cyclesEndpoint)
 	metricsEndpoint := "/metrics"
 	http.Handle(metricsEndpoint, promhttp.Handler())
 	log.Printf("Metrics endpoint is %s", metricsEndpoint)
@@ -463,19 +697,40 @@ func main() {
 	opts.SetAutoReconnect(true)
 
 	opts.OnConnectionLost = onConnectionLost
-	opts.OnConnect = onConnected
+	opts.OnConnect = func(client mqtt.Client) { onConnected(boilers) }
 
 	log.Printf("Connecting to MQTT broker %s", opts.Servers[0])
 
+	for _, b := range boilers {
+		for _, topic := range b.retainedTopics() {
+			retainTopics[topic] = true
+		}
+	}
+
 	mqttClient = mqtt.NewClient(opts)
 	if token := mqttClient.Connect(); token.Wait() && token.Error() != nil {
 		log.Fatal(token.Error())
 	}
 
-	publishAllHomieAttributes()
+	// the state file takes precedence, the boilers without a persisted state
+	// are restored from the values retained by the broker
+	var unrestored []*Boiler
+	for _, b := range boilers {
+		if !b.restoreState() {
+			unrestored = append(unrestored, b)
+		}
+	}
+	if getEnv("HARGASSNER_MQTT_RESTORE", "false") == "true" && len(unrestored) > 0 {
+		timeout := getEnvDuration("HARGASSNER_MQTT_RESTORE_TIMEOUT", 2*time.Second)
+		if err := restoreFromMQTT(mqttClient, unrestored, timeout); err != nil {
+			log.Fatalf("could not restore the state from MQTT: %v", err)
+		}
+	}
 
-	log.Printf("Reading from on %s", serialDevice)
-	reader := bufio.NewReader(port)
+	for _, b := range boilers {
+		b.publishHomieAttributes()
+	}
+	startedUp.Store(true)
 
 	// handle signals for graceful shutdown
 	sigs := make(chan os.Signal, 1)
@@ -483,112 +738,198 @@ func main() {
 
 	done := make(chan bool, 1)
 
+	// the watchdog switches the Homie state when the data stream stalls or recovers
 	go func() {
-		reader.ReadString('\n')
-
-		for {
-			line, err := reader.ReadString('\n')
-			if err != nil {
-				if err.Error() != "Port has been closed" {
-					log.Printf("error reading from serial: %v", err)
-				}
-				done <- true
-				return
-			}
-
-			line, err = strconv.Unquote(strings.Replace(strconv.Quote(line), `\\x`, `\x`, -1))
-			if err != nil {
-				log.Printf("error unquoting line: %v", err)
-				continue
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			for _, b := range boilers {
+				b.updateHomieState()
+				b.rolloverStatistik()
+				b.checkZuendungTimeout()
 			}
+		}
+	}()
 
-			fields := strings.Fields(strings.TrimSpace(line))
-
-			//log.Printf("Received fields: %s", strings.Join(fields, "|"))
-
-			if len(fields) > 0 {
-				switch fields[0] {
-				case "pm":
-					err := parseStatusRecord(fields, statusRecord)
-					if err != nil {
-						log.Println("Error parsing status record:", err)
-						continue
-					}
-				case "z":
-					{
-						handleZRecord(fields, line)
-					}
-				default:
-					fmt.Print("Unknown record receive:" + line)
-				}
-			}
+	var readers sync.WaitGroup
+	for _, b := range boilers {
+		log.Printf("Reading %s from %s", b.ID, b.reader.source)
+		b.reader.onConnect = func() {
+			b.sourceConnected.Store(true)
+			b.updateHomieState()
 		}
+		b.reader.onDisconnect = func() {
+			b.sourceConnected.Store(false)
+			b.updateHomieState()
+		}
+		readers.Go(func() {
+			if err := b.reader.Run(b.handleLine); err != nil {
+				log.Printf("error reading from %s: %v", b.reader.source, err)
+			}
+		})
+	}
+	go func() {
+		readers.Wait()
+		done <- true
 	}()
 
 	select {
 	case <-sigs:
 		log.Println("Received signal, shutting down...")
 	case <-done:
-		log.Println("Serial reader finished, shutting down...")
+		log.Println("Reader finished, shutting down...")
+	}
+
+	for _, b := range boilers {
+		b.reader.Close()
 	}
 
 	if mqttClient != nil && mqttClient.IsConnected() {
 		log.Println("Setting Homie state to disconnected")
-		homieDevice.SetState(homie.StateDisconnected)
-		publishAllHomieAttributes()
+		for _, b := range boilers {
+			b.setHomieState(homie.StateDisconnected)
+			b.publishHomieAttributes()
+		}
 		mqttClient.Disconnect(250)
 	}
-	port.Close()
 	log.Println("Shutdown complete")
 }
 
-func handleZRecord(fields []string, line string) {
+// processLine handles a single line received from the boiler and dispatches
+// it by its record type ("pm" or "z").
+func (b *Boiler) processLine(line string) {
+	line = b.charset.decode(line)
+
+	fields := strings.Fields(strings.TrimSpace(line))
+
+	//log.Printf("Received fields: %s", strings.Join(fields, "|"))
+
+	if len(fields) > 0 {
+		switch fields[0] {
+		case "pm":
+			b.watchdog.Seen("pm")
+			b.observePmFieldCount(len(fields))
+			// records are only reported as malformed once the number of fields is stable
+			if b.statusRecord.profile != nil || b.pmFieldCountRecords >= pmProfileStableRecords {
+				if err := parseStatusRecord(fields, b.statusRecord, b.now()); err != nil {
+					b.countParseErrors(err, line)
+				}
+			}
+			if ascheaustragung := &b.statusRecord.MotorCurrentAshDischarge; b.statusRecord.received[ascheaustragung] {
+				b.entaschung.observeStrom(ascheaustragung.Value)
+			}
+			b.cycles.sample(b.statusRecord)
+			// truncated or garbled records would add properties for positions which do not exist
+			if b.raw != nil && b.pmFieldCountStable() && b.raw.publish(fields) && mqttClient != nil && mqttClient.IsConnected() {
+				b.sendHomieAttributes()
+			}
+		case "z":
+			b.watchdog.Seen("z")
+			if err := b.handleZRecord(fields); err != nil {
+				b.countParseErrors(err, line)
+			}
+			b.saveState()
+		default:
+			b.countParseErrors(newParseError("unknown", "", "unknown record type %q", fields[0]), line)
+		}
+	}
+}
+
+// handleZRecord processes an event record of the boiler. Malformed records are
+// returned as *parseError.
+func (b *Boiler) handleZRecord(fields []string) error {
+	kesselRecord := b.kesselRecord
+	stoerungRecord := b.stoerungRecord
 
 	log.Printf("Handling Z record: fields:[%s]", strings.Join(fields, "|"))
 
+	if len(fields) < 3 {
+		return newParseError("z", "", "expected at least 3 fields, got %d", len(fields))
+	}
+
+	received := b.now()
+	timestamp, err := anchorZTime(fields[1], received)
+	if err != nil {
+		return newParseError("z", "time", "%w", err)
+	}
+	b.uhr.update(timestamp, received)
+
 	if fields[2] == "Kessel" && len(fields) >= 4 {
-		timestamp, err := time.Parse("15:04:05", fields[1])
-		if err == nil {
-			field3 := fields[3]
-			isZuendung := strings.HasPrefix(field3, "Z") && strings.HasSuffix(field3, "ndung")
-			if !isZuendung && strings.HasPrefix(field3, "Z") && (strings.HasSuffix(field3, "ndungen") || strings.Contains(field3, "ndung")) {
-				isZuendung = true
+		field3 := fields[3]
+
+		switch {
+		case field3 == "Zündung" && len(fields) == 4:
+			// "z|14:10:40|Kessel|Zündung" -> Start der Zündung
+			// The sub phases follow as "z|14:10:40|Kessel|Zündung|Start"
+			kesselRecord.lastZuendungStart = timestamp
+			kesselRecord.AnzahlZuendungen.SetValue(kesselRecord.AnzahlZuendungen.Value + 1)
+			kesselRecord.LetzteZuendung.SetValue(timestamp.Format(time.RFC3339))
+			b.statistik.add(received, timestamp, burnStats{Zuendungen: 1})
+			kesselRecord.startZuendung()
+			b.cycles.start(timestamp)
+			b.fehlzuendung.zuendung(received)
+		case field3 == "Zündung":
+			// "z|14:12:20|Kessel|Zündung|Einschub" -> Beginn einer Phase der Zündung
+			kesselRecord.startZuendungPhase(fields[4], timestamp)
+		case field3 == "Leistungsbrand":
+			// "z|14:20:20|Kessel|Leistungsbrand" -> Beginn Leistungsbrand
+			// Zündung endet hier
+			if !kesselRecord.lastZuendungStart.IsZero() {
+				duration := timestamp.Sub(kesselRecord.lastZuendungStart)
+				kesselRecord.DauerLetzteZuendung.SetValue(int(duration.Seconds()))
+				b.statistik.addDuration(received, kesselRecord.lastZuendungStart, timestamp, func(seconds float64) burnStats {
+					return burnStats{ZuendungSekunden: seconds}
+				})
+				kesselRecord.lastZuendungStart = time.Time{} // Reset
 			}
-
-			switch {
-			case isZuendung:
-				if len(fields) >= 4 {
-					// "z|14:10:40|Kessel|Zündung" -> Start der Zündung
-					kesselRecord.lastZuendungStart = timestamp
-					kesselRecord.AnzahlZuendungen.SetValue(kesselRecord.AnzahlZuendungen.Value + 1)
-				}
-			case field3 == "Leistungsbrand":
-				// "z|14:20:20|Kessel|Leistungsbrand" -> Beginn Leistungsbrand
-				// Zündung endet hier
-				if !kesselRecord.lastZuendungStart.IsZero() {
-					duration := timestamp.Sub(kesselRecord.lastZuendungStart)
-					kesselRecord.DauerLetzteZuendung.SetValue(int(duration.Seconds()))
-					kesselRecord.lastZuendungStart = time.Time{} // Reset
-				}
-				kesselRecord.lastLeistungsbrandStart = timestamp
-			case field3 == "Aus":
-				// "z|18:00:32|Kessel|Aus" -> Leistungsbrand endet
-				if !kesselRecord.lastLeistungsbrandStart.IsZero() {
-					duration := timestamp.Sub(kesselRecord.lastLeistungsbrandStart)
-					kesselRecord.DauerLetzterLeistungsbrand.SetValue(int(duration.Seconds()))
-					kesselRecord.lastLeistungsbrandStart = time.Time{} // Reset
-				}
+			kesselRecord.endZuendung(timestamp)
+			kesselRecord.lastLeistungsbrandStart = timestamp
+			kesselRecord.LetzterLeistungsbrand.SetValue(timestamp.Format(time.RFC3339))
+			b.cycles.leistungsbrand(timestamp)
+			b.fehlzuendung.erfolgreich()
+		case field3 == "Aus":
+			// "z|18:00:32|Kessel|Aus" -> Leistungsbrand endet
+			// Aus während der Zündung -> Zündung fehlgeschlagen
+			kesselRecord.endZuendung(timestamp)
+			kesselRecord.lastZuendungStart = time.Time{}
+			b.fehlzuendung.fehlgeschlagen(fehlzuendungAus, timestamp)
+			if !kesselRecord.lastLeistungsbrandStart.IsZero() {
+				duration := timestamp.Sub(kesselRecord.lastLeistungsbrandStart)
+				kesselRecord.DauerLetzterLeistungsbrand.SetValue(int(duration.Seconds()))
+				b.statistik.addDuration(received, kesselRecord.lastLeistungsbrandStart, timestamp, func(seconds float64) burnStats {
+					return burnStats{LeistungsbrandSekunden: seconds}
+				})
+				kesselRecord.lastLeistungsbrandStart = time.Time{} // Reset
+			}
+			b.cycles.end(timestamp)
+		}
+		if field3 == "Entaschung" {
+			// "z|17:50:18|Kessel|Entaschung|Gebläse" -> Schritt der Entaschung
+			step := ""
+			if len(fields) > 4 {
+				step = fields[4]
+			}
+			if b.entaschung.startSchritt(step, timestamp) {
+				b.statistik.add(received, timestamp, burnStats{Entaschungen: 1})
 			}
+			b.cycles.entaschung(timestamp)
+		} else {
+			b.entaschung.end(timestamp)
 		}
+		kesselRecord.handleKesselEvent(field3, timestamp)
 	}
 
-	isStoerung := strings.HasPrefix(fields[2], "St") && strings.HasSuffix(fields[2], "rung")
+	isStoerung := fields[2] == "Störung" || fields[2] == "Stoerung"
 	if isStoerung {
 		// 0.1........2........3...4.5
 		// z 18:39:41 Stoerung Set 7 Stop:1
 		// 0.1........2.......3....4
 		// z 18:40:16 Störung Quit 0007
 
+		if len(fields) < 5 {
+			return newParseError("z", "", "expected at least 5 fields in Störung record, got %d", len(fields))
+		}
+
 		var active bool
 		switch fields[3] {
 		case "Set":
@@ -596,34 +937,36 @@ func handleZRecord(fields []string, line string) {
 		case "Quit":
 			active = false
 		default:
-			log.Printf("Unexpected value of fields[3] (%s): %s (line: %s)", fields[3], "Set or Quit", line)
-			return
+			return newParseError("z", "action", "unexpected value %q, expected Set or Quit", fields[3])
 		}
 
 		stoerNr, err := strconv.Atoi(fields[4])
 		if err != nil {
-			log.Printf("Unexpected value of fields[4] (%s): %s (line: %s)", fields[4], err, line)
-			return
+			return newParseError("z", "stoerNr", "%w", err)
 		}
 		stoerungText := getStoerungText(stoerNr)
 
 		if active {
 			log.Printf("Störung %d: %s", stoerNr, stoerungText)
+			b.statistik.add(received, timestamp, burnStats{Stoerungen: 1})
+			// e.g. Störung 10 "Zündzeit überschritten"
+			b.fehlzuendung.fehlgeschlagen(fehlzuendungStoerung, timestamp)
 		} else {
 			log.Printf("Quit Störung %d: %s", stoerNr, stoerungText)
 		}
 
-		lastChange := fields[1]
-
 		stoerungRecord.StoerungNr.SetValue(stoerNr)
 		stoerungRecord.StoerungText.SetValue(stoerungText)
 		stoerungRecord.StoerungActive.SetValue(active)
-		stoerungRecord.LastActive.SetValue(lastChange)
+		stoerungRecord.LastActive.SetValue(timestamp.Format(time.RFC3339))
+		b.statusRecord.setStoerungSensorFault(stoerNr, active)
+		kesselRecord.setStoerung(active, timestamp)
 
 	} else {
 		message := strings.Join(fields[2:], " ")
-		meldung.SetValue(message)
+		b.meldung.SetValue(message)
 	}
+	return nil
 }
 
 type StoerungRequest struct {
@@ -637,22 +980,25 @@ type StoerungResponse struct {
 	Since        string `json:"since"`
 }
 
-func handleStoerung(w http.ResponseWriter, r *http.Request) {
+func (b *Boiler) handleStoerung(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
 
 	switch r.Method {
 	case "POST":
-		setStoerungHandler(w, r)
+		b.setStoerungHandler(w, r)
 	case "DELETE":
-		resetStoerungHandler(w)
+		b.resetStoerungHandler(w)
 	case "GET":
-		getStoerung(w)
+		b.getStoerung(w)
 	default:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 
 }
 
-func getStoerung(w http.ResponseWriter) {
+func (b *Boiler) getStoerung(w http.ResponseWriter) {
+	stoerungRecord := b.stoerungRecord
 
 	if !stoerungRecord.StoerungActive.Value {
 		http.Error(w, "No Stoerung", http.StatusNotFound)
@@ -669,7 +1015,8 @@ func getStoerung(w http.ResponseWriter) {
 	}
 }
 
-func setStoerungHandler(w http.ResponseWriter, r *http.Request) {
+func (b *Boiler) setStoerungHandler(w http.ResponseWriter, r *http.Request) {
+	stoerungRecord := b.stoerungRecord
 
 	var req StoerungRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -684,18 +1031,24 @@ func setStoerungHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	stoerungRecord.StoerungText.SetValue(req.StoerMeldung)
 	stoerungRecord.StoerungActive.SetValue(true)
-	stoerungRecord.LastActive.SetValue(time.Now().Format("15:04:05"))
+	stoerungRecord.LastActive.SetValue(time.Now().Format(time.RFC3339))
+	b.kesselRecord.setStoerung(true, time.Now())
+	b.saveState()
 
 	w.WriteHeader(http.StatusOK)
 	fmt.Fprintf(w, "Störung updated successfully")
 }
 
-func resetStoerungHandler(w http.ResponseWriter) {
+func (b *Boiler) resetStoerungHandler(w http.ResponseWriter) {
+	stoerungRecord := b.stoerungRecord
+
 	// Reset the stoerungRecord to default values
 	stoerungRecord.StoerungActive.SetValue(false)
 	stoerungRecord.StoerungNr.SetValue(0)
 	stoerungRecord.StoerungText.SetValue("")
-	stoerungRecord.LastActive.SetValue(time.Now().Format("15:04:05"))
+	stoerungRecord.LastActive.SetValue(time.Now().Format(time.RFC3339))
+	b.kesselRecord.setStoerung(false, time.Now())
+	b.saveState()
 
 	w.WriteHeader(http.StatusOK)
 	fmt.Fprintf(w, "Störung reset successfully")
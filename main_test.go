@@ -49,13 +49,15 @@ func TestGetStoerungText_KnownAndUnknown(t *testing.T) {
 }
 
 func TestHandleZRecord_SetAndQuit(t *testing.T) {
-	// ensure fresh stoerungRecord for test
-	stoerungRecord = newEmptyStoerungRecord(nodeStoerung)
+	b := newBoiler("hargassner", "Hargassner Heizung")
+	b.now = testClock
+	stoerungRecord := b.stoerungRecord
 
 	// Simulate a Set event
 	fieldsSet := []string{"z", "18:39:41", "Stoerung", "Set", "7"}
-	lineSet := strings.Join([]string{"z", "18:39:41", "Stoerung", "Set", "7", "Stop:1"}, " ")
-	handleZRecord(fieldsSet, lineSet)
+	if err := b.handleZRecord(fieldsSet); err != nil {
+		t.Fatal(err)
+	}
 
 	if stoerungRecord.StoerungNr.Value != 7 {
 		t.Fatalf("after Set expected StoerungNr 7, got %v", stoerungRecord.StoerungNr.Value)
@@ -69,8 +71,9 @@ func TestHandleZRecord_SetAndQuit(t *testing.T) {
 
 	// Simulate a Quit event with padded number "0007"
 	fieldsQuit := []string{"z", "18:40:16", "Stoerung", "Quit", "0007"}
-	lineQuit := "z 18:40:16 Stoerung Quit 0007"
-	handleZRecord(fieldsQuit, lineQuit)
+	if err := b.handleZRecord(fieldsQuit); err != nil {
+		t.Fatal(err)
+	}
 
 	if stoerungRecord.StoerungNr.Value != 7 {
 		t.Fatalf("after Quit expected StoerungNr 7, got %v", stoerungRecord.StoerungNr.Value)
@@ -78,9 +81,9 @@ func TestHandleZRecord_SetAndQuit(t *testing.T) {
 	if stoerungRecord.StoerungActive.Value {
 		t.Fatalf("after Quit expected StoerungActive false, got true")
 	}
-	// LastActive should be set to the time field from input (fields[1])
-	if stoerungRecord.LastActive.Value != "18:40:16" {
-		t.Fatalf("after Quit expected LastActive %q, got %q", "18:40:16", stoerungRecord.LastActive.Value)
+	// LastActive should be set to the time field from input (fields[1]) on the date of the receive time
+	if stoerungRecord.LastActive.Value != "2026-02-14T18:40:16Z" {
+		t.Fatalf("after Quit expected LastActive %q, got %q", "2026-02-14T18:40:16Z", stoerungRecord.LastActive.Value)
 	}
 }
 
@@ -89,38 +92,48 @@ func TestHandleZRecord_Duration(t *testing.T) {
 	// 2026/02/14 13:31:25 Handling Z record: fields:[z|14:20:20|Kessel|Leistungsbrand] <-- Hier beginnt der Leistungsbrand
 	// 2026/02/14 17:11:37 Handling Z record: fields:[z|18:00:32|Kessel|Aus] <-- Leistungsbrand endet
 
-	kesselRecord = newEmptyKesselRecord(nodeKessel)
+	b := newBoiler("hargassner", "Hargassner Heizung")
+	b.now = testClock
+	kesselRecord := b.kesselRecord
 
 	// Start Zündung
-	handleZRecord([]string{"z", "14:10:40", "Kessel", "Zündung"}, "z 14:10:40 Kessel Zündung")
+	b.handleZRecord([]string{"z", "14:10:40", "Kessel", "Zündung"})
 	if kesselRecord.AnzahlZuendungen.Value != 1 {
 		t.Fatalf("expected AnzahlZuendungen 1, got %d", kesselRecord.AnzahlZuendungen.Value)
 	}
 
 	// Start Leistungsbrand (Zündung endet)
 	// 14:10:40 bis 14:20:20 sind 9 Minuten und 40 Sekunden = 540 + 40 = 580 Sekunden
-	handleZRecord([]string{"z", "14:20:20", "Kessel", "Leistungsbrand"}, "z 14:20:20 Kessel Leistungsbrand")
+	b.handleZRecord([]string{"z", "14:20:20", "Kessel", "Leistungsbrand"})
 	if kesselRecord.DauerLetzteZuendung.Value != 580 {
 		t.Fatalf("expected DauerLetzteZuendung 580, got %d", kesselRecord.DauerLetzteZuendung.Value)
 	}
 
-	// Test mit anderer Kodierung/Schreibweise, die durch Z.*ndung abgedeckt sein sollte
-	handleZRecord([]string{"z", "14:30:00", "Kessel", "Zündungen"}, "z 14:30:00 Kessel Zündungen")
+	// Die Unterphasen der Zündung zählen nicht als weitere Zündung
+	b.handleZRecord([]string{"z", "14:30:00", "Kessel", "Zündung"})
+	b.handleZRecord([]string{"z", "14:30:00", "Kessel", "Zündung", "Start"})
+	b.handleZRecord([]string{"z", "14:32:00", "Kessel", "Zündung", "Einschub"})
 	if kesselRecord.AnzahlZuendungen.Value != 2 {
-		t.Fatalf("expected AnzahlZuendungen 2 after Zündungen, got %d", kesselRecord.AnzahlZuendungen.Value)
+		t.Fatalf("expected AnzahlZuendungen 2 after second Zündung, got %d", kesselRecord.AnzahlZuendungen.Value)
 	}
-	handleZRecord([]string{"z", "14:35:00", "Kessel", "Leistungsbrand"}, "z 14:35:00 Kessel Leistungsbrand")
+	b.handleZRecord([]string{"z", "14:35:00", "Kessel", "Leistungsbrand"})
 	// 14:30:00 bis 14:35:00 sind 5 Minuten = 300 Sekunden
 	if kesselRecord.DauerLetzteZuendung.Value != 300 {
 		t.Fatalf("expected DauerLetzteZuendung 300, got %d", kesselRecord.DauerLetzteZuendung.Value)
 	}
 
-	// Noch ein Test für Zndung
-	handleZRecord([]string{"z", "14:40:00", "Kessel", "Zndung"}, "z 14:40:00 Kessel Zndung")
+	// Andere Schreibweisen sind keine Zündung
+	b.handleZRecord([]string{"z", "14:38:00", "Kessel", "Zündungen"})
+	b.handleZRecord([]string{"z", "14:39:00", "Kessel", "Zndung"})
+	if kesselRecord.AnzahlZuendungen.Value != 2 {
+		t.Fatalf("expected AnzahlZuendungen 2 after misspelled events, got %d", kesselRecord.AnzahlZuendungen.Value)
+	}
+
+	b.handleZRecord([]string{"z", "14:40:00", "Kessel", "Zündung"})
 	if kesselRecord.AnzahlZuendungen.Value != 3 {
-		t.Fatalf("expected AnzahlZuendungen 3 after Zndung, got %d", kesselRecord.AnzahlZuendungen.Value)
+		t.Fatalf("expected AnzahlZuendungen 3, got %d", kesselRecord.AnzahlZuendungen.Value)
 	}
-	handleZRecord([]string{"z", "14:45:00", "Kessel", "Leistungsbrand"}, "z 14:45:00 Kessel Leistungsbrand")
+	b.handleZRecord([]string{"z", "14:45:00", "Kessel", "Leistungsbrand"})
 	if kesselRecord.DauerLetzteZuendung.Value != 300 {
 		t.Fatalf("expected DauerLetzteZuendung 300 (second time), got %d", kesselRecord.DauerLetzteZuendung.Value)
 	}
@@ -131,12 +144,64 @@ func TestHandleZRecord_Duration(t *testing.T) {
 	// 17:45:00 -> 18:00:00 sind 15 Minuten = 900s
 	// 18:00:00 -> 18:00:32 sind 32s
 	// Gesamt: 10800 + 900 + 32 = 11732
-	handleZRecord([]string{"z", "18:00:32", "Kessel", "Aus"}, "z 18:00:32 Kessel Aus")
+	b.handleZRecord([]string{"z", "18:00:32", "Kessel", "Aus"})
 	if kesselRecord.DauerLetzterLeistungsbrand.Value != 11732 {
 		t.Fatalf("expected DauerLetzterLeistungsbrand 11732, got %d", kesselRecord.DauerLetzterLeistungsbrand.Value)
 	}
 }
 
+func TestProcessLine_DecodesCharset(t *testing.T) {
+	b := newBoiler("hargassner", "Hargassner Heizung")
+	kesselRecord := b.kesselRecord
+	stoerungRecord := b.stoerungRecord
+
+	// "Zündung" and "Störung" in CP850
+	b.processLine("z 14:10:40 Kessel Z\x81ndung\r\n")
+	if kesselRecord.AnzahlZuendungen.Value != 1 {
+		t.Fatalf("expected AnzahlZuendungen 1, got %d", kesselRecord.AnzahlZuendungen.Value)
+	}
+
+	b.processLine("z 18:39:41 St\x94rung Set 5 Stop:1\r\n")
+	if !stoerungRecord.StoerungActive.Value || stoerungRecord.StoerungNr.Value != 5 {
+		t.Fatalf("expected active Störung 5, got %v %d", stoerungRecord.StoerungActive.Value, stoerungRecord.StoerungNr.Value)
+	}
+	if stoerungRecord.StoerungText.Value != "Sicherheitsthermostat (STB)" {
+		t.Fatalf("unexpected Störung text %q", stoerungRecord.StoerungText.Value)
+	}
+}
+
+func TestBoilers_AreIndependent(t *testing.T) {
+	first := newBoiler("hargassner", "Hargassner Heizung")
+	second := newBoiler("hargassner-2", "Hargassner Heizung 2")
+
+	first.processLine("z 18:39:41 Störung Set 5 Stop:1")
+	if !first.stoerungRecord.StoerungActive.Value {
+		t.Fatalf("expected active Störung on first boiler")
+	}
+	if second.stoerungRecord.StoerungActive.Value {
+		t.Fatalf("expected no Störung on second boiler")
+	}
+
+	boilers := []*Boiler{first, second}
+	mux := http.NewServeMux()
+	handler := boilerHandler(boilers, (*Boiler).handleStoerung)
+	mux.HandleFunc("/stoerung", handler)
+	mux.HandleFunc("/stoerung/{boiler}", handler)
+
+	for path, want := range map[string]string{
+		"/stoerung":              "Sicherheitsthermostat",
+		"/stoerung/hargassner":   "Sicherheitsthermostat",
+		"/stoerung/hargassner-2": "No Stoerung",
+		"/stoerung/unknown":      "Unknown boiler",
+	} {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if !strings.Contains(rr.Body.String(), want) {
+			t.Errorf("GET %s: expected %q in response, got %q", path, want, rr.Body.String())
+		}
+	}
+}
+
 // Optional helper to ensure strconv.Atoi behavior for padded numbers (ensures test expectations)
 func TestAtoiPadded(t *testing.T) {
 	v, err := strconv.Atoi("0007")
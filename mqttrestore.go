@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// persistentTopics returns the persisted status fields of the boiler keyed by
+// the topics of their Homie properties.
+func (b *Boiler) persistentTopics() map[string]persistentField {
+	topics := make(map[string]persistentField)
+	for key, field := range b.persistentFields() {
+		nodeID, propertyID, _ := strings.Cut(key, "/")
+		node := b.device.Node(nodeID)
+		if node == nil {
+			continue
+		}
+		if property := node.Property(propertyID); property != nil {
+			topics[property.GetValue().Topic] = field
+		}
+	}
+	return topics
+}
+
+// retainedTopics returns the topics of the properties whose values are
+// retained by the broker: the persisted fields and the statistics.
+func (b *Boiler) retainedTopics() []string {
+	topics := slices.Collect(maps.Keys(b.persistentTopics()))
+	for _, property := range b.statistik.properties() {
+		topics = append(topics, property.GetValue().Topic)
+	}
+	return topics
+}
+
+// restoreFromMQTT seeds the Kessel and Störung records of the boilers with the
+// values retained by the broker. It subscribes to the topics of the persisted
+// properties of all boilers and collects the retained values until timeout.
+// An error is returned if the subscription fails or is not acknowledged within
+// timeout, starting anyway would overwrite the retained values.
+func restoreFromMQTT(client mqtt.Client, boilers []*Boiler, timeout time.Duration) error {
+	filters := make(map[string]byte)
+	for _, b := range boilers {
+		for topic := range b.persistentTopics() {
+			filters[topic] = 0
+		}
+	}
+
+	var mu sync.Mutex
+	retained := make(map[string]string)
+	token := client.SubscribeMultiple(filters, func(client mqtt.Client, message mqtt.Message) {
+		if !message.Retained() {
+			return
+		}
+		mu.Lock()
+		retained[message.Topic()] = string(message.Payload())
+		mu.Unlock()
+	})
+	if !token.WaitTimeout(timeout) {
+		return fmt.Errorf("subscription to the retained topics not acknowledged within %s", timeout)
+	}
+	if token.Error() != nil {
+		return fmt.Errorf("could not subscribe to the retained topics: %w", token.Error())
+	}
+	time.Sleep(timeout)
+	if token := client.Unsubscribe(slices.Collect(maps.Keys(filters))...); !token.WaitTimeout(timeout) {
+		log.Printf("Unsubscribe from the retained topics not acknowledged within %s", timeout)
+	} else if token.Error() != nil {
+		log.Printf("could not unsubscribe from the retained topics: %v", token.Error())
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, b := range boilers {
+		b.mu.Lock()
+		b.restoreRetained(retained)
+		b.mu.Unlock()
+	}
+	return nil
+}
+
+// restoreRetained sets the persisted fields from retained values keyed by
+// topic. The start times of the phases in progress are derived from the
+// operating state. The caller must hold b.mu.
+func (b *Boiler) restoreRetained(retained map[string]string) {
+	restored := 0
+	for topic, field := range b.persistentTopics() {
+		value, ok := retained[topic]
+		if !ok || value == "" {
+			continue
+		}
+		if err := field.parse(value); err != nil {
+			log.Printf("could not restore %s from %q: %v", topic, value, err)
+			continue
+		}
+		restored++
+	}
+	if restored == 0 {
+		log.Printf("No retained values of %s found", b.ID)
+		return
+	}
+
+	kessel := b.kesselRecord
+	kessel.stoerung = b.stoerungRecord.StoerungActive.Value
+	zustand := kesselZustand(kessel.Zustand.Value)
+	if zustand == "" {
+		zustand = zustandUnbekannt
+	}
+	if zustand != zustandStoerung {
+		kessel.kesselZustand = zustand
+	}
+	retainedTime := func(field StatusField[string]) time.Time {
+		t, err := time.Parse(time.RFC3339, field.Value)
+		if err != nil {
+			return time.Time{}
+		}
+		return t
+	}
+	switch kessel.kesselZustand {
+	case zustandZuendung:
+		kessel.lastZuendungStart = retainedTime(kessel.LetzteZuendung)
+		b.fehlzuendung.zuendung(b.now())
+	case zustandLeistungsbrand:
+		kessel.lastLeistungsbrandStart = retainedTime(kessel.LetzterLeistungsbrand)
+	case zustandEntaschung:
+		kessel.lastLeistungsbrandStart = retainedTime(kessel.LetzterLeistungsbrand)
+		if start := retainedTime(b.entaschung.LetzteEntaschung); !start.IsZero() {
+			b.entaschung.start = start
+			b.entaschung.dauer = make(map[string]time.Duration)
+		}
+	}
+	kessel.publishZustand(zustand)
+	log.Printf("Restored %d retained values of %s", restored, b.ID)
+}
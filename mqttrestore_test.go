@@ -0,0 +1,194 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+func TestRestoreRetained(t *testing.T) {
+	b := newBoiler("retained", "Retained")
+	b.now = testClock
+	b.restoreRetained(map[string]string{
+		"homie/retained/kessel/AnzahlZuendungen":      "41",
+		"homie/retained/kessel/DauerLetzteZuendung":   "580",
+		"homie/retained/kessel/LetzterLeistungsbrand": "2026-02-14T14:20:20Z",
+		"homie/retained/kessel/zustand":               "leistungsbrand",
+		"homie/retained/stoerung/nr":                  "7",
+		"homie/retained/stoerung/active":              "false",
+		"homie/retained/prozesswerte/meldung":         "ignored",
+	})
+
+	kessel := b.kesselRecord
+	if kessel.AnzahlZuendungen.Value != 41 || kessel.DauerLetzteZuendung.Value != 580 {
+		t.Fatalf("unexpected restored values %d %d", kessel.AnzahlZuendungen.Value, kessel.DauerLetzteZuendung.Value)
+	}
+	if b.stoerungRecord.StoerungNr.Value != 7 || b.stoerungRecord.StoerungActive.Value {
+		t.Fatalf("unexpected restored Störung %d %v", b.stoerungRecord.StoerungNr.Value, b.stoerungRecord.StoerungActive.Value)
+	}
+	if b.meldung.Value != "" {
+		t.Fatalf("expected meldung not to be restored")
+	}
+	if !kessel.lastLeistungsbrandStart.Equal(time.Date(2026, 2, 14, 14, 20, 20, 0, time.UTC)) {
+		t.Fatalf("expected start of the Leistungsbrand in progress, got %s", kessel.lastLeistungsbrandStart)
+	}
+
+	b.processLine("z 14:21:20 Kessel Aus")
+	if kessel.DauerLetzterLeistungsbrand.Value != 60 {
+		t.Fatalf("expected DauerLetzterLeistungsbrand 60, got %d", kessel.DauerLetzterLeistungsbrand.Value)
+	}
+	b.processLine("z 15:00:00 Kessel Zündung")
+	if kessel.AnzahlZuendungen.Value != 42 {
+		t.Fatalf("expected AnzahlZuendungen to continue with 42, got %d", kessel.AnzahlZuendungen.Value)
+	}
+}
+
+// fakeToken is a completed token, or one which never completes if timeout is set
+type fakeToken struct {
+	timeout bool
+	err     error
+}
+
+func (t *fakeToken) Wait() bool                     { return !t.timeout }
+func (t *fakeToken) WaitTimeout(time.Duration) bool { return !t.timeout }
+func (t *fakeToken) Done() <-chan struct{} {
+	done := make(chan struct{})
+	if !t.timeout {
+		close(done)
+	}
+	return done
+}
+func (t *fakeToken) Error() error { return t.err }
+
+type fakeMessage struct {
+	mqtt.Message
+	topic    string
+	payload  string
+	retained bool
+}
+
+func (m *fakeMessage) Topic() string   { return m.topic }
+func (m *fakeMessage) Payload() []byte { return []byte(m.payload) }
+func (m *fakeMessage) Retained() bool  { return m.retained }
+
+// fakeRetainedClient delivers the messages of the subscribed topics on subscribe
+type fakeRetainedClient struct {
+	mqtt.Client
+	messages   []*fakeMessage
+	subscribe  *fakeToken
+	subscribes int
+	filters    map[string]byte
+}
+
+func (c *fakeRetainedClient) SubscribeMultiple(filters map[string]byte, callback mqtt.MessageHandler) mqtt.Token {
+	c.subscribes++
+	c.filters = filters
+	if c.subscribe != nil {
+		return c.subscribe
+	}
+	for _, message := range c.messages {
+		if _, ok := filters[message.topic]; ok {
+			callback(c, message)
+		}
+	}
+	return &fakeToken{}
+}
+
+func (c *fakeRetainedClient) Unsubscribe(topics ...string) mqtt.Token {
+	return &fakeToken{}
+}
+
+func TestRestoreFromMQTT(t *testing.T) {
+	first := newBoiler("first", "First")
+	second := newBoiler("second", "Second")
+	client := &fakeRetainedClient{messages: []*fakeMessage{
+		{topic: "homie/first/kessel/AnzahlZuendungen", payload: "41", retained: true},
+		{topic: "homie/second/kessel/AnzahlZuendungen", payload: "7", retained: true},
+		// values published after the subscription are no retained state
+		{topic: "homie/second/kessel/DauerLetzteZuendung", payload: "580"},
+	}}
+
+	if err := restoreFromMQTT(client, []*Boiler{first, second}, 10*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if client.subscribes != 1 {
+		t.Fatalf("expected one subscription for all boilers, got %d", client.subscribes)
+	}
+	if _, ok := client.filters["homie/second/stoerung/nr"]; !ok {
+		t.Fatalf("expected the topics of all boilers in %v", client.filters)
+	}
+	if first.kesselRecord.AnzahlZuendungen.Value != 41 || second.kesselRecord.AnzahlZuendungen.Value != 7 {
+		t.Fatalf("unexpected restored values %d %d", first.kesselRecord.AnzahlZuendungen.Value, second.kesselRecord.AnzahlZuendungen.Value)
+	}
+	if second.kesselRecord.DauerLetzteZuendung.Value != 0 {
+		t.Fatalf("expected the value which is not retained to be ignored")
+	}
+}
+
+func TestRestoreFromMQTT_SubscribeFails(t *testing.T) {
+	for name, token := range map[string]*fakeToken{
+		"timeout": {timeout: true},
+		"error":   {err: errors.New("not authorized")},
+	} {
+		b := newBoiler("restore-"+name, "Restore")
+		client := &fakeRetainedClient{subscribe: token}
+		if err := restoreFromMQTT(client, []*Boiler{b}, 10*time.Millisecond); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+// fakePublishClient records the retain flag of the published topics
+type fakePublishClient struct {
+	mqtt.Client
+	retained map[string]bool
+}
+
+func (c *fakePublishClient) Publish(topic string, qos byte, retained bool, payload interface{}) mqtt.Token {
+	c.retained[topic] = retained
+	return &fakeToken{}
+}
+
+func TestPublish_RetainsPersistedValues(t *testing.T) {
+	b := newBoiler("retain", "Retain")
+	client := &fakePublishClient{retained: make(map[string]bool)}
+	defer func(client mqtt.Client, topics map[string]bool) { mqttClient, retainTopics = client, topics }(mqttClient, retainTopics)
+	mqttClient, retainTopics = client, make(map[string]bool)
+	for _, topic := range b.retainedTopics() {
+		retainTopics[topic] = true
+	}
+
+	for topic, want := range map[string]bool{
+		"homie/retain/kessel/AnzahlZuendungen":   true,
+		"homie/retain/stoerung/nr":               true,
+		"homie/retain/statistik/heuteZuendungen": true,
+		"homie/retain/statistik/saison":          true,
+		"homie/retain/prozesswerte/meldung":      false,
+	} {
+		publish(topic, "1")
+		if client.retained[topic] != want {
+			t.Errorf("expected retained %v for %s", want, topic)
+		}
+	}
+}
+
+func TestOnConnected_PublishesAfterStartup(t *testing.T) {
+	b := newBoiler("connected", "Connected")
+	client := &fakePublishClient{retained: make(map[string]bool)}
+	defer func(client mqtt.Client) { mqttClient = client; startedUp.Store(false) }(mqttClient)
+	mqttClient = client
+
+	// a connect before the restore must not overwrite the retained values
+	onConnected([]*Boiler{b})
+	if len(client.retained) != 0 {
+		t.Fatalf("expected nothing published before the startup, got %v", client.retained)
+	}
+
+	startedUp.Store(true)
+	onConnected([]*Boiler{b})
+	if _, ok := client.retained[b.device.GetStateTopic()]; !ok {
+		t.Fatalf("expected the Homie attributes to be published on a reconnect, got %v", client.retained)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var parseErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "hargassner_parse_errors_total",
+	Help: "Anzahl der fehlerhaften Datensätze und Felder",
+}, []string{"boiler", "record", "field"})
+
+func init() {
+	prometheus.MustRegister(parseErrors)
+}
+
+// parseError is a malformed record or field received from the boiler
+type parseError struct {
+	// record is the record type "pm", "z" or "unknown"
+	record string
+	// field is the name of the malformed field, empty if the record as a whole is malformed
+	field string
+	err   error
+}
+
+func newParseError(record, field string, format string, args ...any) *parseError {
+	return &parseError{record: record, field: field, err: fmt.Errorf(format, args...)}
+}
+
+func (e *parseError) Error() string {
+	if e.field == "" {
+		return fmt.Sprintf("malformed %s record: %v", e.record, e.err)
+	}
+	return fmt.Sprintf("malformed field %s of %s record: %v", e.field, e.record, e.err)
+}
+
+func (e *parseError) Unwrap() error {
+	return e.err
+}
+
+// countParseErrors logs the parse errors in err and counts them per record
+// type and field.
+func (b *Boiler) countParseErrors(err error, line string) {
+	errs := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	}
+	for _, err := range errs {
+		var parseErr *parseError
+		if !errors.As(err, &parseErr) {
+			parseErr = &parseError{record: "unknown", err: err}
+		}
+		parseErrors.WithLabelValues(b.ID, parseErr.record, parseErr.field).Inc()
+		log.Printf("%s: %v (line: %q)", b.ID, parseErr, line)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestProcessLine_CountsParseErrors(t *testing.T) {
+	b := newBoiler("parse-errors", "Parse Errors")
+
+	b.processLine(hsvLine(70))
+	b.processLine(strings.Replace(hsvLine(70), " 7.5 ", " 7,5 ", 1))
+	b.processLine("pm 50 60")
+	b.processLine("z 18:39")
+	b.processLine("z 18:39:41 Störung Set")
+	b.processLine("z 18:39:41 Störung Set x")
+	b.processLine("z 18:39:4x Kessel Zündung")
+	b.processLine("xy 1 2 3")
+
+	for _, c := range []struct {
+		record, field string
+	}{
+		{"pm", "prozesswerte/o2InAbgas"},
+		{"pm", ""},
+		{"z", "stoerNr"},
+		{"z", "time"},
+		{"unknown", ""},
+	} {
+		if got := testutil.ToFloat64(parseErrors.WithLabelValues("parse-errors", c.record, c.field)); got != 1 {
+			t.Errorf("expected 1 parse error for record %q field %q, got %v", c.record, c.field, got)
+		}
+	}
+	if got := testutil.ToFloat64(parseErrors.WithLabelValues("parse-errors", "z", "")); got != 2 {
+		t.Errorf("expected 2 malformed z records, got %v", got)
+	}
+	// the other fields of the record are still taken
+	if b.statusRecord.O2InExhaustGas.Value != 7.5 || b.statusRecord.BoilerTemperature.Value != 70 {
+		t.Errorf("unexpected values %v %d", b.statusRecord.O2InExhaustGas.Value, b.statusRecord.BoilerTemperature.Value)
+	}
+}
+
+func TestParseStatusRecord_ReturnsFieldErrors(t *testing.T) {
+	record := newEmptyStatusRecord()
+	if _, err := record.bind(lookupBuiltinPmProfile("hsv")); err != nil {
+		t.Fatal(err)
+	}
+	fields := strings.Fields(hsvLine(70))
+	fields[1], fields[29] = "a", "b"
+
+	err := parseStatusRecord(fields, record, testClock())
+	var parseErr *parseError
+	if !errors.As(err, &parseErr) || parseErr.record != "pm" {
+		t.Fatalf("expected parse error, got %v", err)
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); !ok || len(joined.Unwrap()) != 2 {
+		t.Fatalf("expected two field errors, got %v", err)
+	}
+}
+
+func FuzzParseStatusRecord(f *testing.F) {
+	f.Add(hsvLine(70))
+	f.Add("pm 50 60 7.5")
+	f.Add("pm")
+	record := newEmptyStatusRecord()
+	if _, err := record.bind(lookupBuiltinPmProfile("hsv")); err != nil {
+		f.Fatal(err)
+	}
+	f.Fuzz(func(t *testing.T, line string) {
+		parseStatusRecord(strings.Fields(line), record, testClock())
+	})
+}
+
+func FuzzHandleZRecord(f *testing.F) {
+	f.Add("z 14:10:40 Kessel Zündung")
+	f.Add("z 18:39:41 Störung Set 5 Stop:1")
+	f.Add("z 18:40:16 Stoerung Quit 0007")
+	f.Add("z")
+	b := newBoiler("fuzz", "Fuzz")
+	b.processLine(hsvLine(70))
+	f.Fuzz(func(t *testing.T, line string) {
+		b.handleZRecord(strings.Fields(line))
+		if b.kesselRecord.AnzahlZuendungen.Value < 0 {
+			t.Fatalf("negative number of ignitions")
+		}
+	})
+}
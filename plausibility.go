@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// plausibilityMode defines what happens with implausible values
+type plausibilityMode string
+
+const (
+	// plausibilitySuppress keeps the last plausible value
+	plausibilitySuppress plausibilityMode = "suppress"
+	// plausibilityFlag publishes implausible values but sets the sensorFault property
+	plausibilityFlag plausibilityMode = "flag"
+	// plausibilityOff disables the checks
+	plausibilityOff plausibilityMode = "off"
+)
+
+func parsePlausibilityMode(name string) (plausibilityMode, error) {
+	switch mode := plausibilityMode(name); mode {
+	case plausibilitySuppress, plausibilityFlag, plausibilityOff:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unsupported plausibility mode %q (supported: suppress, flag, off)", name)
+	}
+}
+
+var rejectedSamples = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "hargassner_rejected_samples_total",
+	Help: "Anzahl der unplausiblen Werte (reason range oder rate)",
+}, []string{"boiler", "field", "reason"})
+
+func init() {
+	prometheus.MustRegister(rejectedSamples)
+}
+
+// sensorStoerungen maps the Störungen of broken sensors to the fields measured by the sensor
+var sensorStoerungen = map[int]string{
+	16: "prozesswerte/rauchgasTemperatur",
+	17: "prozesswerte/rauchgasTemperatur",
+	18: "prozesswerte/kesselTemperatur",
+	19: "prozesswerte/kesselTemperatur",
+	20: "prozesswerte/boiler1Temperatur",
+}
+
+// plausibilityCheck checks the values of a pm field against the valid range
+// and the maximum rate of change of its pm profile.
+type plausibilityCheck struct {
+	boilerID string
+	// field is the "<node>/<id>" of the checked field
+	field   string
+	mapping pmFieldMapping
+	mode    plausibilityMode
+
+	// SensorFault is published as <id>SensorFault
+	SensorFault StatusField[bool]
+
+	// last is the last plausible value received at lastReceived
+	last         float64
+	lastReceived time.Time
+	hasLast      bool
+	implausible  bool
+	// stoerung is true while the boiler reports a Störung of the sensor
+	stoerung bool
+}
+
+func newPlausibilityCheck(boilerID string, mapping pmFieldMapping, mode plausibilityMode) *plausibilityCheck {
+	return &plausibilityCheck{
+		boilerID: boilerID,
+		field:    mapping.Node + "/" + mapping.Id,
+		mapping:  mapping,
+		mode:     mode,
+		SensorFault: StatusField[bool]{
+			Id:   mapping.Id + "SensorFault",
+			Name: MultiLanguageString{EN: mapping.Name.EN + " Sensor Fault", DE: "Fühlerfehler " + mapping.Name.DE},
+		},
+	}
+}
+
+// accept checks a value of the pm field received at received and reports
+// whether it should be published. The rate of change is measured from the
+// last plausible value, over at least one pm interval as records buffered
+// during a reconnect arrive at once.
+func (c *plausibilityCheck) accept(value float64, received time.Time) bool {
+	reason := ""
+	switch {
+	case math.IsNaN(value) || math.IsInf(value, 0),
+		c.mapping.Min != nil && value < *c.mapping.Min, c.mapping.Max != nil && value > *c.mapping.Max:
+		reason = "range"
+	case c.mapping.MaxRate != nil && c.hasLast &&
+		math.Abs(value-c.last) > *c.mapping.MaxRate*max(received.Sub(c.lastReceived), pmInterval).Seconds():
+		reason = "rate"
+	}
+
+	if reason == "" {
+		c.last, c.lastReceived, c.hasLast = value, received, true
+		c.setImplausible(false)
+		return true
+	}
+	rejectedSamples.WithLabelValues(c.boilerID, c.field, reason).Inc()
+	c.setImplausible(true)
+	return c.mode == plausibilityFlag
+}
+
+func (c *plausibilityCheck) setImplausible(implausible bool) {
+	c.implausible = implausible
+	c.SensorFault.SetValue(c.implausible || c.stoerung)
+}
+
+func (c *plausibilityCheck) setStoerung(active bool) {
+	c.stoerung = active
+	c.SensorFault.SetValue(c.implausible || c.stoerung)
+}
+
+// hasLimits reports whether the pm profile defines limits for the field
+func (m pmFieldMapping) hasLimits() bool {
+	return m.Min != nil || m.Max != nil || m.MaxRate != nil
+}
+
+// createPlausibilityChecks creates the checks of the fields of the bound pm
+// profile with limits. The caller must hold b.mu.
+func (b *Boiler) createPlausibilityChecks() {
+	record := b.statusRecord
+	record.checks = make([]*plausibilityCheck, len(record.bound))
+	if b.plausibilityMode == plausibilityOff {
+		return
+	}
+	for i, mapping := range record.profile.Fields {
+		if !mapping.hasLimits() {
+			continue
+		}
+		check := newPlausibilityCheck(b.ID, mapping, b.plausibilityMode)
+		registerStatusField(&check.SensorFault, b.device.Node(mapping.Node), mapping.Node, b.ID)
+		record.checks[i] = check
+	}
+}
+
+// setStoerungSensorFault sets the sensorFault property of the field measured by
+// the sensor of a Störung.
+func (r *StatusRecord) setStoerungSensorFault(stoerNr int, active bool) {
+	field, ok := sensorStoerungen[stoerNr]
+	if !ok {
+		return
+	}
+	for _, check := range r.checks {
+		if check != nil && check.field == field {
+			check.setStoerung(active)
+		}
+	}
+}
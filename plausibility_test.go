@@ -0,0 +1,132 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// hsvLine returns a pm record of the HSV firmware with the given boiler temperature
+func hsvLine(boilerTemperature int) string {
+	fields := strings.Fields("pm 50 60 7.5 71 150 3.2 4.1 45.0 38.0 45.0 38.0 60 55 30 65 -12.0 -11.5 -12.0 0 0 0 0 52.0 20.0 20.0 0 0 0 1.20 0.00 0.50")
+	fields[4] = strconv.Itoa(boilerTemperature)
+	return strings.Join(fields, " ")
+}
+
+func TestPlausibility_SuppressesOutOfRange(t *testing.T) {
+	b := newBoiler("plausibility-range", "Plausibility Range")
+	b.processLine(hsvLine(70))
+	check := b.statusRecord.checks[3]
+	if check == nil || check.field != "prozesswerte/kesselTemperatur" {
+		t.Fatalf("expected plausibility check of kesselTemperatur, got %+v", check)
+	}
+
+	b.processLine(hsvLine(-50))
+	if b.statusRecord.BoilerTemperature.Value != 70 {
+		t.Fatalf("expected suppressed value, got %d", b.statusRecord.BoilerTemperature.Value)
+	}
+	if !check.SensorFault.Value {
+		t.Fatalf("expected sensor fault")
+	}
+	if got := testutil.ToFloat64(rejectedSamples.WithLabelValues("plausibility-range", "prozesswerte/kesselTemperatur", "range")); got != 1 {
+		t.Fatalf("expected 1 rejected sample, got %v", got)
+	}
+	if b.device.Node("prozesswerte").Property("kesselTemperaturSensorFault") == nil {
+		t.Fatalf("expected Homie property kesselTemperaturSensorFault")
+	}
+
+	b.processLine(hsvLine(71))
+	if b.statusRecord.BoilerTemperature.Value != 71 || check.SensorFault.Value {
+		t.Fatalf("expected recovery, got %d (fault %v)", b.statusRecord.BoilerTemperature.Value, check.SensorFault.Value)
+	}
+}
+
+func TestPlausibility_RateOfChange(t *testing.T) {
+	b := newBoiler("plausibility-rate", "Plausibility Rate")
+	now := testClock()
+	b.now = func() time.Time { return now }
+	b.processLine(hsvLine(70))
+
+	// kesselTemperatur may change by 2 K per second
+	for i := 1; i < 10; i++ {
+		now = now.Add(time.Second)
+		b.processLine(hsvLine(90))
+		if b.statusRecord.BoilerTemperature.Value != 70 {
+			t.Fatalf("record %d: expected suppressed jump, got %d", i, b.statusRecord.BoilerTemperature.Value)
+		}
+	}
+	now = now.Add(time.Second)
+	b.processLine(hsvLine(90))
+	if b.statusRecord.BoilerTemperature.Value != 90 {
+		t.Fatalf("expected value after 10 seconds, got %d", b.statusRecord.BoilerTemperature.Value)
+	}
+	if got := testutil.ToFloat64(rejectedSamples.WithLabelValues("plausibility-rate", "prozesswerte/kesselTemperatur", "rate")); got != 9 {
+		t.Fatalf("expected 9 rejected samples, got %v", got)
+	}
+}
+
+func TestPlausibility_RateOverTime(t *testing.T) {
+	b := newBoiler("plausibility-rate-time", "Plausibility Rate Time")
+	now := testClock()
+	b.now = func() time.Time { return now }
+	b.processLine(hsvLine(70))
+
+	// the change is allowed for the time between the records, not per record
+	now = now.Add(5 * time.Second)
+	b.processLine(hsvLine(80))
+	if b.statusRecord.BoilerTemperature.Value != 80 {
+		t.Fatalf("expected a change of 10 K within 5 seconds, got %d", b.statusRecord.BoilerTemperature.Value)
+	}
+
+	// records arriving at once are allowed the change of one pm interval
+	b.processLine(hsvLine(82))
+	b.processLine(hsvLine(90))
+	if b.statusRecord.BoilerTemperature.Value != 82 {
+		t.Fatalf("expected the jump within a burst to be suppressed, got %d", b.statusRecord.BoilerTemperature.Value)
+	}
+}
+
+func TestPlausibility_FlagMode(t *testing.T) {
+	b := newBoiler("plausibility-flag", "Plausibility Flag")
+	b.plausibilityMode = plausibilityFlag
+	b.processLine(hsvLine(70))
+	b.processLine(hsvLine(999))
+	if b.statusRecord.BoilerTemperature.Value != 999 {
+		t.Fatalf("expected flagged value to be published, got %d", b.statusRecord.BoilerTemperature.Value)
+	}
+	if !b.statusRecord.checks[3].SensorFault.Value {
+		t.Fatalf("expected sensor fault")
+	}
+}
+
+func TestPlausibility_Off(t *testing.T) {
+	b := newBoiler("plausibility-off", "Plausibility Off")
+	b.plausibilityMode = plausibilityOff
+	b.processLine(hsvLine(70))
+	b.processLine(hsvLine(-50))
+	if b.statusRecord.BoilerTemperature.Value != -50 {
+		t.Fatalf("expected unchecked value, got %d", b.statusRecord.BoilerTemperature.Value)
+	}
+}
+
+func TestPlausibility_SensorStoerung(t *testing.T) {
+	b := newBoiler("plausibility-stoerung", "Plausibility Störung")
+	b.processLine(hsvLine(70))
+	check := b.statusRecord.checks[3]
+
+	b.processLine("z 18:39:41 Störung Set 19 Stop:1")
+	if !check.SensorFault.Value {
+		t.Fatalf("expected sensor fault during Störung 19 (Kesselfühler Unterbrechung)")
+	}
+	b.processLine(hsvLine(70))
+	if !check.SensorFault.Value {
+		t.Fatalf("expected sensor fault to stay while the Störung is active")
+	}
+	b.processLine("z 18:45:00 Störung Quit 0019")
+	if check.SensorFault.Value {
+		t.Fatalf("expected no sensor fault after Quit")
+	}
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/creativeprojects/go-homie"
+	"go.yaml.in/yaml/v2"
+)
+
+// pmProfile maps the fields of the pm records of a firmware or boiler model
+// to status fields.
+type pmProfile struct {
+	Name string `json:"name" yaml:"name"`
+	// FieldCount is the number of fields of a pm record including the leading "pm"
+	FieldCount int              `json:"fieldCount" yaml:"fieldCount"`
+	Fields     []pmFieldMapping `json:"fields" yaml:"fields"`
+}
+
+// pmFieldMapping describes the status field filled from the pm field at Index.
+type pmFieldMapping struct {
+	Index int    `json:"index" yaml:"index"`
+	Id    string `json:"id" yaml:"id"`
+	// Node is the ID of the Homie node of the field, e.g. "prozesswerte"
+	Node string `json:"node" yaml:"node"`
+	// Type is the Homie data type "integer", "float" or "string", default is "float"
+	Type string              `json:"type" yaml:"type"`
+	Unit string              `json:"unit" yaml:"unit"`
+	Name MultiLanguageString `json:"name" yaml:"name"`
+
+	// Min and Max are the limits of plausible values
+	Min *float64 `json:"min,omitempty" yaml:"min,omitempty"`
+	Max *float64 `json:"max,omitempty" yaml:"max,omitempty"`
+	// MaxRate is the maximum plausible change per second
+	MaxRate *float64 `json:"maxRate,omitempty" yaml:"maxRate,omitempty"`
+}
+
+//go:embed profiles/*.yaml
+var builtinProfileFiles embed.FS
+
+// builtinPmProfiles are the profiles shipped with the monitor
+var builtinPmProfiles = func() []*pmProfile {
+	entries, err := builtinProfileFiles.ReadDir("profiles")
+	if err != nil {
+		panic(err)
+	}
+	var profiles []*pmProfile
+	for _, entry := range entries {
+		name := path.Join("profiles", entry.Name())
+		data, err := builtinProfileFiles.ReadFile(name)
+		if err != nil {
+			panic(err)
+		}
+		profile, err := parsePmProfile(name, data)
+		if err != nil {
+			panic(err)
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles
+}()
+
+// parsePmProfile parses a profile in JSON (*.json) or YAML format.
+func parsePmProfile(name string, data []byte) (*pmProfile, error) {
+	var profile pmProfile
+	var err error
+	if strings.EqualFold(filepath.Ext(name), ".json") {
+		err = json.Unmarshal(data, &profile)
+	} else {
+		err = yaml.UnmarshalStrict(data, &profile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid pm profile %s: %w", name, err)
+	}
+	if err := profile.validate(); err != nil {
+		return nil, fmt.Errorf("invalid pm profile %s: %w", name, err)
+	}
+	return &profile, nil
+}
+
+func (p *pmProfile) validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("missing name")
+	}
+	if p.FieldCount < 2 {
+		return fmt.Errorf("fieldCount must be at least 2")
+	}
+	indexes := make(map[int]bool)
+	ids := make(map[string]bool)
+	for i := range p.Fields {
+		field := &p.Fields[i]
+		if field.Type == "" {
+			field.Type = string(homie.TypeFloat)
+		}
+		switch {
+		case field.Index < 1 || field.Index >= p.FieldCount:
+			return fmt.Errorf("index %d of field %s is outside of 1..%d", field.Index, field.Id, p.FieldCount-1)
+		case indexes[field.Index]:
+			return fmt.Errorf("index %d is mapped twice", field.Index)
+		case !homie.IsValidID(field.Id) || !homie.IsValidID(field.Node):
+			return fmt.Errorf("invalid id %q or node %q of field %d", field.Id, field.Node, field.Index)
+		case ids[field.Node+"/"+field.Id]:
+			return fmt.Errorf("field %s/%s is mapped twice", field.Node, field.Id)
+		}
+		switch homie.PropertyType(field.Type) {
+		case homie.TypeInteger, homie.TypeFloat:
+		case homie.TypeString:
+			if field.hasLimits() {
+				return fmt.Errorf("string field %s must not have limits", field.Id)
+			}
+		default:
+			return fmt.Errorf("unsupported type %q of field %s", field.Type, field.Id)
+		}
+		if field.Min != nil && field.Max != nil && *field.Min > *field.Max {
+			return fmt.Errorf("min of field %s is greater than max", field.Id)
+		}
+		if field.MaxRate != nil && *field.MaxRate <= 0 {
+			return fmt.Errorf("maxRate of field %s must be positive", field.Id)
+		}
+		if field.Name.EN == "" {
+			field.Name.EN = field.Id
+		}
+		if field.Name.DE == "" {
+			field.Name.DE = field.Name.EN
+		}
+		indexes[field.Index] = true
+		ids[field.Node+"/"+field.Id] = true
+	}
+	return nil
+}
+
+// loadPmProfiles returns the profiles configured by spec, a comma separated
+// list of built-in profile names and profile files. "auto" stands for all
+// built-in profiles.
+func loadPmProfiles(spec string) ([]*pmProfile, error) {
+	var profiles []*pmProfile
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if entry == "auto" {
+			profiles = append(profiles, builtinPmProfiles...)
+			continue
+		}
+		if profile := lookupBuiltinPmProfile(entry); profile != nil {
+			profiles = append(profiles, profile)
+			continue
+		}
+		data, err := os.ReadFile(entry)
+		if err != nil {
+			return nil, fmt.Errorf("unknown pm profile %q: %w", entry, err)
+		}
+		profile, err := parsePmProfile(entry, data)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, profile)
+	}
+	if len(profiles) == 0 {
+		return nil, fmt.Errorf("no pm profile configured")
+	}
+	return profiles, nil
+}
+
+func lookupBuiltinPmProfile(name string) *pmProfile {
+	for _, profile := range builtinPmProfiles {
+		if strings.EqualFold(profile.Name, name) {
+			return profile
+		}
+	}
+	return nil
+}
+
+// selectPmProfile returns the profile for pm records with fieldCount fields.
+// A profile with exactly this number of fields is preferred, otherwise the
+// largest profile fitting into the record is taken and the additional fields
+// are ignored.
+func selectPmProfile(profiles []*pmProfile, fieldCount int) *pmProfile {
+	var selected *pmProfile
+	for _, profile := range profiles {
+		if profile.FieldCount == fieldCount {
+			return profile
+		}
+		if profile.FieldCount < fieldCount && (selected == nil || profile.FieldCount > selected.FieldCount) {
+			selected = profile
+		}
+	}
+	return selected
+}
+
+// withHeizkreise returns the profile without the fields of the heating
+// circuits (nodes heizkreis<n>) above count.
+func (p *pmProfile) withHeizkreise(count int) *pmProfile {
+	filtered := *p
+	filtered.Fields = nil
+	for _, field := range p.Fields {
+		if circuit, err := strconv.Atoi(strings.TrimPrefix(field.Node, "heizkreis")); err == nil && circuit > count {
+			continue
+		}
+		filtered.Fields = append(filtered.Fields, field)
+	}
+	return &filtered
+}
+
+// pmNodeName returns the name of the Homie node with the ID of a profile
+func pmNodeName(id string) string {
+	switch {
+	case id == "prozesswerte":
+		return "Prozesswerte"
+	case strings.HasPrefix(id, "heizkreis"):
+		return "Heizkreis " + strings.TrimPrefix(id, "heizkreis")
+	default:
+		return id
+	}
+}
+
+// applyPmProfile binds the status record of the boiler to profile and
+// registers the mapped fields. The caller must hold b.mu.
+func (b *Boiler) applyPmProfile(profile *pmProfile) error {
+	previous := b.statusRecord.properties()
+	bound, err := b.statusRecord.bind(profile)
+	if err != nil {
+		return err
+	}
+	for i, field := range bound {
+		mapping := profile.Fields[i]
+		node := b.device.Node(mapping.Node)
+		if node == nil {
+			node = b.device.AddNode(mapping.Node, pmNodeName(mapping.Node), pmNodeName(mapping.Node))
+		}
+		field.register(node, mapping.Node, b.ID)
+	}
+	b.createPlausibilityChecks()
+	removed := b.removePmProperties(previous)
+	if mqttClient != nil && mqttClient.IsConnected() {
+		// clear the retained attributes of the removed properties
+		for _, topic := range removed {
+			mqttClient.Publish(topic, 0, true, "")
+		}
+		b.sendHomieAttributes()
+	}
+	return nil
+}
+
+// properties returns the Homie properties of the bound pm profile and of its
+// plausibility checks keyed by "<node>/<id>".
+func (r *StatusRecord) properties() map[string]bool {
+	properties := make(map[string]bool)
+	if r.profile == nil {
+		return properties
+	}
+	for i, mapping := range r.profile.Fields {
+		properties[mapping.Node+"/"+mapping.Id] = true
+		if i < len(r.checks) && r.checks[i] != nil {
+			properties[mapping.Node+"/"+r.checks[i].SensorFault.Id] = true
+		}
+	}
+	return properties
+}
+
+// removePmProperties removes the Homie properties and the Prometheus series
+// of the previous pm profile which the bound profile does not map. It returns
+// the topics of the attributes of the removed properties. The caller must
+// hold b.mu.
+func (b *Boiler) removePmProperties(previous map[string]bool) []string {
+	current := b.statusRecord.properties()
+	stale := make(map[string]map[string]bool)
+	for key := range previous {
+		if current[key] {
+			continue
+		}
+		nodeID, id, _ := strings.Cut(key, "/")
+		if stale[nodeID] == nil {
+			stale[nodeID] = make(map[string]bool)
+		}
+		stale[nodeID][id] = true
+		deleteBoilerGauge(statusGaugeName(nodeID, id), b.ID)
+	}
+	var removed []string
+	for nodeID, ids := range stale {
+		removed = append(removed, b.removeHomieProperties(nodeID, ids)...)
+		log.Printf("Removed %d properties of node %s of %s", len(ids), nodeID, b.ID)
+	}
+	return removed
+}
+
+// removeHomieProperties removes the properties with ids from the Homie node
+// and returns the topics of their attributes. go-homie cannot remove a
+// property, the node is replaced by a copy built from its Homie attributes.
+// The fields keep setting their values through the original properties, which
+// publish to the same topics.
+func (b *Boiler) removeHomieProperties(nodeID string, ids map[string]bool) []string {
+	prefix := path.Join(path.Dir(b.device.GetStateTopic()), nodeID) + "/"
+	attributes := make(map[string]string)
+	var removed []string
+	for _, attribute := range b.device.GetHomieAttributes() {
+		key, ok := strings.CutPrefix(attribute.Topic, prefix)
+		if !ok {
+			continue
+		}
+		attributes[key] = attribute.Value
+		if id, _, _ := strings.Cut(key, "/"); ids[id] {
+			removed = append(removed, attribute.Topic)
+		}
+	}
+
+	node := b.device.AddNode(nodeID, attributes["$name"], attributes["$type"])
+	for _, id := range strings.Split(attributes["$properties"], ",") {
+		if id == "" || ids[id] {
+			continue
+		}
+		node.AddProperty(id, attributes[id+"/$name"], homie.PropertyType(attributes[id+"/$datatype"])).
+			SetUnit(attributes[id+"/$unit"]).
+			SetFormat(attributes[id+"/$format"]).
+			Settable(attributes[id+"/$settable"] == "true").
+			SetRetained(attributes[id+"/$retained"] != "false")
+	}
+	if nodeID == "prozesswerte" {
+		b.nodeProcessWerte = node
+	}
+	return removed
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSelectPmProfile(t *testing.T) {
+	short, err := parsePmProfile("short.yaml", []byte("name: short\nfieldCount: 19\nfields:\n  - {index: 4, id: kesselTemperatur, node: prozesswerte, type: integer}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	profiles := append([]*pmProfile{short}, builtinPmProfiles...)
+	for fieldCount, want := range map[int]string{
+		19: "short",
+		25: "short",
+		32: "hsv",
+		40: "hsv",
+	} {
+		profile := selectPmProfile(profiles, fieldCount)
+		if profile == nil || profile.Name != want {
+			t.Errorf("expected profile %s for %d fields, got %v", want, fieldCount, profile)
+		}
+	}
+	if profile := selectPmProfile(profiles, 10); profile != nil {
+		t.Errorf("expected no profile for 10 fields, got %s", profile.Name)
+	}
+	if len(builtinPmProfiles) != 1 || builtinPmProfiles[0].Name != "hsv" {
+		t.Errorf("expected the verified hsv profile only, got %v", builtinPmProfiles)
+	}
+}
+
+func TestLoadPmProfiles_Files(t *testing.T) {
+	dir := t.TempDir()
+	yamlFile := filepath.Join(dir, "custom.yaml")
+	os.WriteFile(yamlFile, []byte(`name: custom
+fieldCount: 6
+fields:
+  - index: 1
+    id: kesselTemperatur
+    node: prozesswerte
+    type: integer
+    unit: "°C"
+    name:
+      de: Kesseltemperatur
+      en: Boiler Temperature
+  - index: 5
+    id: pufferTemperatur
+    node: puffer
+    unit: "°C"
+`), 0o644)
+	jsonFile := filepath.Join(dir, "custom.json")
+	os.WriteFile(jsonFile, []byte(`{"name": "json", "fieldCount": 3, "fields": [{"index": 2, "id": "status", "node": "prozesswerte", "type": "string"}]}`), 0o644)
+
+	profiles, err := loadPmProfiles("hsv, " + yamlFile + "," + jsonFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(profiles) != 3 || profiles[0].Name != "hsv" || profiles[1].Name != "custom" || profiles[2].Name != "json" {
+		t.Fatalf("unexpected profiles %v", profiles)
+	}
+	if field := profiles[1].Fields[1]; field.Type != "float" || field.Name.DE != "pufferTemperatur" {
+		t.Fatalf("expected defaults for type and name, got %+v", field)
+	}
+
+	if _, err := loadPmProfiles(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Fatalf("expected error for missing profile")
+	}
+}
+
+func TestParsePmProfile_Invalid(t *testing.T) {
+	for name, data := range map[string]string{
+		"index outside":    "name: x\nfieldCount: 3\nfields:\n  - {index: 3, id: a, node: n}\n",
+		"index twice":      "name: x\nfieldCount: 3\nfields:\n  - {index: 1, id: a, node: n}\n  - {index: 1, id: b, node: n}\n",
+		"field twice":      "name: x\nfieldCount: 3\nfields:\n  - {index: 1, id: a, node: n}\n  - {index: 2, id: a, node: n}\n",
+		"invalid type":     "name: x\nfieldCount: 3\nfields:\n  - {index: 1, id: a, node: n, type: boolean}\n",
+		"invalid id":       "name: x\nfieldCount: 3\nfields:\n  - {index: 1, id: a_b, node: n}\n",
+		"unknown property": "name: x\nfieldCount: 3\nfields:\n  - {index: 1, id: a, node: n, factor: 10}\n",
+		"missing name":     "fieldCount: 3\n",
+	} {
+		if _, err := parsePmProfile("test.yaml", []byte(data)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestProcessLine_SelectsPmProfile(t *testing.T) {
+	b := newBoiler("profile-test", "Profile Test")
+	custom, err := parsePmProfile("custom.yaml", []byte(`name: custom
+fieldCount: 5
+fields:
+  - {index: 1, id: kesselTemperatur, node: prozesswerte, type: integer}
+  - {index: 4, id: pufferTemperatur, node: puffer}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.pmProfiles = append([]*pmProfile{custom}, builtinPmProfiles...)
+
+	b.processLine("pm 71 0 0 42.5")
+	if b.statusRecord.profile == nil || b.statusRecord.profile.Name != "custom" {
+		t.Fatalf("expected profile custom, got %v", b.statusRecord.profile)
+	}
+	if b.statusRecord.BoilerTemperature.Value != 71 {
+		t.Fatalf("expected Kesseltemperatur 71, got %d", b.statusRecord.BoilerTemperature.Value)
+	}
+	if len(b.statusRecord.Other) != 1 {
+		t.Fatalf("expected one additional field, got %d", len(b.statusRecord.Other))
+	}
+	if value := b.statusRecord.Other[0].(*StatusField[float64]).Value; value != 42.5 {
+		t.Fatalf("expected pufferTemperatur 42.5, got %v", value)
+	}
+	if b.device.Node("puffer") == nil || b.device.Node("puffer").Property("pufferTemperatur") == nil {
+		t.Fatalf("expected Homie property puffer/pufferTemperatur")
+	}
+
+	// the profile stays selected, shorter records are rejected
+	if err := parseStatusRecord(strings.Fields("pm 70 0"), b.statusRecord, testClock()); err == nil {
+		t.Fatalf("expected error for short pm record")
+	}
+}
+
+func TestProcessLine_TruncatedFirstRecord(t *testing.T) {
+	b := newBoiler("truncated-test", "Truncated Test")
+	fields := strings.Fields(hsvLine(71))
+
+	// a fragment after a reconnect does not select a profile
+	b.processLine(strings.Join(fields[:25], " "))
+	if b.statusRecord.profile != nil {
+		t.Fatalf("expected no profile for a truncated record, got %s", b.statusRecord.profile.Name)
+	}
+	if got := testutil.ToFloat64(parseErrors.WithLabelValues("truncated-test", "pm", "")); got != 0 {
+		t.Fatalf("expected no parse error before the profile is selected, got %v", got)
+	}
+
+	b.processLine(hsvLine(71))
+	if b.statusRecord.profile == nil || b.statusRecord.profile.Name != "hsv" {
+		t.Fatalf("expected profile hsv, got %v", b.statusRecord.profile)
+	}
+	if b.statusRecord.BoilerTemperature.Value != 71 {
+		t.Fatalf("expected Kesseltemperatur 71, got %d", b.statusRecord.BoilerTemperature.Value)
+	}
+}
+
+func TestProcessLine_ReselectsPmProfile(t *testing.T) {
+	b := newBoiler("reselect-test", "Reselect Test")
+	b.plausibilityMode = plausibilityOff
+	custom, err := parsePmProfile("custom.yaml", []byte(`name: custom
+fieldCount: 25
+fields:
+  - {index: 4, id: kesselTemperatur, node: prozesswerte, type: integer}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.pmProfiles = append([]*pmProfile{custom}, b.pmProfiles...)
+	fields := strings.Fields(hsvLine(71))
+
+	// a fragment with exactly the fields of a profile selects it
+	b.processLine(strings.Join(fields[:25], " "))
+	if b.statusRecord.profile == nil || b.statusRecord.profile.Name != "custom" {
+		t.Fatalf("expected profile custom, got %v", b.statusRecord.profile)
+	}
+
+	// the profile is selected again once the number of fields is stable
+	for i := range pmProfileStableRecords {
+		b.processLine(hsvLine(72))
+		if name := b.statusRecord.profile.Name; (i < pmProfileStableRecords-1) != (name == "custom") {
+			t.Fatalf("unexpected profile %s after %d HSV records", name, i+1)
+		}
+	}
+	if b.device.Node("prozesswerte").Property("stromEinschub") == nil {
+		t.Fatalf("expected Homie property stromEinschub of the hsv profile")
+	}
+	b.processLine(hsvLine(73))
+	if b.statusRecord.BoilerTemperature.Value != 73 || b.statusRecord.MotorCurrentRoomDischarge.Value != 0.5 {
+		t.Fatalf("unexpected values %d %v", b.statusRecord.BoilerTemperature.Value, b.statusRecord.MotorCurrentRoomDischarge.Value)
+	}
+}
+
+func TestProcessLine_HSVRecord(t *testing.T) {
+	b := newBoiler("hsv-test", "HSV Test")
+	b.processLine("pm 50 60 7.5 71 150 3.2 4.1 45.0 38.0 45.0 38.0 60 55 30 65 -12.0 -11.5 -12.0 0 0 0 0 52.0 20.0 20.0 0 0 0 1.20 0.00 0.50\r\n")
+	if b.statusRecord.profile == nil || b.statusRecord.profile.Name != "hsv" {
+		t.Fatalf("expected profile hsv, got %v", b.statusRecord.profile)
+	}
+	record := b.statusRecord
+	if record.BoilerTemperature.Value != 71 || record.O2InExhaustGas.Value != 7.5 || record.FlowTemperatureCircuit2.Value != 38.0 || record.MotorCurrentRoomDischarge.Value != 0.5 {
+		t.Fatalf("unexpected values %d %v %v %v", record.BoilerTemperature.Value, record.O2InExhaustGas.Value, record.FlowTemperatureCircuit2.Value, record.MotorCurrentRoomDischarge.Value)
+	}
+}
+
+func TestProcessLine_Heizkreise(t *testing.T) {
+	line := "pm 50 60 7.5 71 150 3.2 4.1 45.0 38.0 45.0 38.0 60 55 30 65 -12.0 -11.5 -12.0 41.0 36.0 42.0 37.0 52.0 20.0 20.0 21.0 19.5 0 1.20 0.00 0.50"
+
+	two := newBoiler("heizkreise-2", "Zwei Heizkreise")
+	two.processLine(line)
+	if two.device.Node("heizkreis2") == nil || two.device.Node("heizkreis3") != nil || two.device.Node("heizkreis4") != nil {
+		t.Fatalf("expected only the nodes of two heating circuits")
+	}
+	if two.statusRecord.FlowTemperatureCircuit3.Value != 0 {
+		t.Fatalf("expected no value for Heizkreis 3, got %v", two.statusRecord.FlowTemperatureCircuit3.Value)
+	}
+
+	four := newBoiler("heizkreise-4", "Vier Heizkreise")
+	four.heizkreise = 4
+	four.processLine(line)
+	if four.device.Node("heizkreis3") == nil || four.device.Node("heizkreis4").Property("HK4FR25") == nil {
+		t.Fatalf("expected the nodes of four heating circuits")
+	}
+	record := four.statusRecord
+	if record.FlowTemperatureCircuit3.Value != 41.0 || record.FlowTemperatureCircuit4.Value != 36.0 ||
+		record.FlowTemperatureCircuit3Set.Value != 42.0 || record.FlowTemperatureCircuit4Set.Value != 37.0 ||
+		record.HK3FR25.Value != 21.0 || record.HK4FR25.Value != 19.5 {
+		t.Fatalf("unexpected Heizkreis 3/4 values %+v", record)
+	}
+}
+
+func TestApplyPmProfile_RemovesStaleProperties(t *testing.T) {
+	b := newBoiler("stale-test", "Stale Test")
+	b.plausibilityMode = plausibilityFlag
+	custom, err := parsePmProfile("custom.yaml", []byte(`name: custom
+fieldCount: 32
+fields:
+  - {index: 4, id: kesselTemperatur, node: prozesswerte, type: integer}
+  - {index: 5, id: zusatzWert, node: prozesswerte, max: 100}
+  - {index: 6, id: zusatzVorlauf, node: heizkreis1}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.applyPmProfile(custom); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.applyPmProfile(lookupBuiltinPmProfile("hsv")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"prozesswerte/zusatzWert", "prozesswerte/zusatzWertSensorFault", "heizkreis1/zusatzVorlauf"} {
+		nodeID, id, _ := strings.Cut(key, "/")
+		if b.device.Node(nodeID).Property(id) != nil {
+			t.Errorf("expected property %s of the previous profile to be removed", key)
+		}
+		if got := testutil.CollectAndCount(promGaugeVecs[statusGaugeName(nodeID, id)]); got != 0 {
+			t.Errorf("expected no gauge series of %s, got %d", key, got)
+		}
+	}
+	attributes := make(map[string]string)
+	for _, attribute := range b.device.GetHomieAttributes() {
+		attributes[attribute.Topic] = attribute.Value
+	}
+	properties := strings.Split(attributes["homie/stale-test/prozesswerte/$properties"], ",")
+	for _, id := range []string{"kesselTemperatur", "stromEinschub", "meldung"} {
+		if !slices.Contains(properties, id) {
+			t.Errorf("expected property %s in %v", id, properties)
+		}
+	}
+	if slices.Contains(properties, "zusatzWert") {
+		t.Errorf("expected no stale property in %v", properties)
+	}
+	if attributes["homie/stale-test/prozesswerte/kesselTemperatur/$unit"] != "°C" {
+		t.Errorf("expected the attributes of the remaining properties, got %v", attributes)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+func openPty() (master *os.File, slave *os.File, err error) {
+	return nil, nil, errors.New("the simulator requires a Linux pseudo-terminal")
+}
+
+func discardStaleInput(slave *os.File) {}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/creativeprojects/go-homie"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var pmRawGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "hargassner_pm_raw",
+	Help: "Numerischer Wert des pm Datensatzes an Position index",
+}, []string{"boiler", "index"})
+
+func init() {
+	prometheus.MustRegister(pmRawGauge)
+}
+
+// pmRawMaxFields limits the positions published, so a garbled record cannot
+// add an unlimited number of properties and series
+const pmRawMaxFields = 64
+
+// rawPmNode publishes every numeric field of the pm records by its position.
+// It helps to find out the meaning of the fields not mapped by the pm profile.
+type rawPmNode struct {
+	boilerID string
+	node     *homie.Node
+	// properties holds the Homie property of each position, nil if not yet seen
+	properties []*homie.Property
+}
+
+func newRawPmNode(device *homie.Device, boilerID string) *rawPmNode {
+	return &rawPmNode{
+		boilerID: boilerID,
+		node:     device.AddNode("raw", "Rohwerte", "Rohwerte"),
+	}
+}
+
+// publish publishes the numeric fields of a pm record up to pmRawMaxFields.
+// It reports whether new Homie properties were added.
+func (r *rawPmNode) publish(fields []string) bool {
+	added := false
+	for index := 1; index < min(len(fields), pmRawMaxFields); index++ {
+		value, err := strconv.ParseFloat(fields[index], 64)
+		if err != nil {
+			continue
+		}
+		for len(r.properties) <= index {
+			r.properties = append(r.properties, nil)
+		}
+		if r.properties[index] == nil {
+			id := "pm" + strconv.Itoa(index)
+			r.properties[index] = r.node.AddProperty(id, "pm "+strconv.Itoa(index), homie.TypeFloat)
+			added = true
+		}
+		r.properties[index].Set(value)
+		pmRawGauge.WithLabelValues(r.boilerID, strconv.Itoa(index)).Set(value)
+	}
+	return added
+}
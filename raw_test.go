@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRawPmNode_PublishesNumericFields(t *testing.T) {
+	b := newBoiler("raw-test", "Raw Test")
+	b.raw = newRawPmNode(b.device, b.ID)
+
+	// records are published once the number of fields is stable
+	b.processLine("pm 50 abc 7.5")
+	if b.device.Node("raw").Property("pm1") != nil {
+		t.Fatalf("expected no properties before the number of fields is stable")
+	}
+	b.processLine("pm 50 abc 7.5")
+	b.processLine("pm 50 abc 7.5")
+	if got := testutil.ToFloat64(pmRawGauge.WithLabelValues("raw-test", "3")); got != 7.5 {
+		t.Fatalf("expected raw value 7.5 at index 3, got %v", got)
+	}
+	node := b.device.Node("raw")
+	if node.Property("pm1") == nil || node.Property("pm2") != nil || node.Property("pm3") == nil {
+		t.Fatalf("expected properties for the numeric fields 1 and 3 only")
+	}
+
+	// longer records add properties
+	if added := b.raw.publish([]string{"pm", "51", "0", "7.4", "-3"}); !added {
+		t.Fatalf("expected new properties for a longer record")
+	}
+	if added := b.raw.publish([]string{"pm", "52", "0", "7.3", "-2"}); added {
+		t.Fatalf("expected no new properties for a record of the same length")
+	}
+	if got := testutil.ToFloat64(pmRawGauge.WithLabelValues("raw-test", "4")); got != -2 {
+		t.Fatalf("expected raw value -2 at index 4, got %v", got)
+	}
+}
+
+func TestRawPmNode_IgnoresGarbledRecords(t *testing.T) {
+	b := newBoiler("raw-garbled", "Raw Garbled")
+	b.raw = newRawPmNode(b.device, b.ID)
+	node := b.device.Node("raw")
+
+	b.processLine(hsvLine(71))
+	if node.Property("pm31") == nil {
+		t.Fatalf("expected properties for the fields of the hsv profile")
+	}
+
+	// two records run together after a reconnect
+	b.processLine(hsvLine(71) + " " + hsvLine(72))
+	if node.Property("pm32") != nil {
+		t.Fatalf("expected no properties for a garbled record")
+	}
+
+	long := make([]string, 200)
+	for i := range long {
+		long[i] = "1"
+	}
+	b.raw.publish(append([]string{"pm"}, long...))
+	if node.Property("pm63") == nil || node.Property("pm64") != nil {
+		t.Fatalf("expected properties up to pm%d only", pmRawMaxFields-1)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// captureTimeFormat is the format of the receive timestamp in front of every captured line
+const captureTimeFormat = time.RFC3339Nano
+
+// captureRecorder writes every raw line received from the boiler together with
+// its receive timestamp into capture files named <prefix>-<date>-<nnn>.log. A
+// new file is started every day and whenever the current file exceeds maxSize.
+//
+// Each line of a capture file has the format "<RFC 3339 timestamp>\t<raw line>".
+type captureRecorder struct {
+	dir string
+	// prefix is the first part of the file names, usually the boiler ID
+	prefix   string
+	maxSize  int64
+	compress bool
+
+	// flushInterval limits how long compressed data may stay in memory
+	flushInterval time.Duration
+
+	mu        sync.Mutex
+	file      *os.File
+	counter   *countingWriter
+	gzip      *gzip.Writer
+	day       string
+	lastFlush time.Time
+	closed    bool
+}
+
+func newCaptureRecorder(dir, prefix string, maxSize int64, compress bool) (*captureRecorder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("could not create capture directory %s: %w", dir, err)
+	}
+	return &captureRecorder{
+		dir:           dir,
+		prefix:        prefix,
+		maxSize:       maxSize,
+		compress:      compress,
+		flushInterval: 10 * time.Second,
+	}, nil
+}
+
+// Record appends a raw line received at the given time to the current capture file.
+func (r *captureRecorder) Record(received time.Time, line string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil
+	}
+
+	day := received.Format("2006-01-02")
+	if r.file == nil || day != r.day || (r.maxSize > 0 && r.counter.n >= r.maxSize) {
+		if err := r.rotate(day); err != nil {
+			return err
+		}
+	}
+
+	if !strings.HasSuffix(line, "\n") {
+		line += "\n"
+	}
+	entry := received.Format(captureTimeFormat) + "\t" + line
+
+	if r.gzip == nil {
+		_, err := io.WriteString(r.counter, entry)
+		return err
+	}
+	if _, err := io.WriteString(r.gzip, entry); err != nil {
+		return err
+	}
+	if received.Sub(r.lastFlush) >= r.flushInterval {
+		r.lastFlush = received
+		return r.gzip.Flush()
+	}
+	return nil
+}
+
+// rotate closes the current capture file and opens the next one for day.
+func (r *captureRecorder) rotate(day string) error {
+	if err := r.closeFile(); err != nil {
+		return err
+	}
+
+	seq, err := r.nextSequence(day)
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s-%03d.log", r.prefix, day, seq)
+	if r.compress {
+		name += ".gz"
+	}
+	file, err := os.OpenFile(filepath.Join(r.dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("could not create capture file: %w", err)
+	}
+
+	r.file = file
+	r.counter = &countingWriter{w: file}
+	r.day = day
+	if r.compress {
+		r.gzip = gzip.NewWriter(r.counter)
+	}
+	return nil
+}
+
+// nextSequence returns the sequence number following the existing capture files of day.
+func (r *captureRecorder) nextSequence(day string) (int, error) {
+	matches, err := filepath.Glob(filepath.Join(r.dir, r.prefix+"-"+day+"-*.log*"))
+	if err != nil {
+		return 0, err
+	}
+	seq := 1
+	for _, match := range matches {
+		name := strings.TrimPrefix(filepath.Base(match), r.prefix+"-"+day+"-")
+		name, _, _ = strings.Cut(name, ".")
+		if n, err := strconv.Atoi(name); err == nil && n >= seq {
+			seq = n + 1
+		}
+	}
+	return seq, nil
+}
+
+func (r *captureRecorder) closeFile() error {
+	if r.file == nil {
+		return nil
+	}
+	var err error
+	if r.gzip != nil {
+		err = r.gzip.Close()
+		r.gzip = nil
+	}
+	if closeErr := r.file.Close(); err == nil {
+		err = closeErr
+	}
+	r.file = nil
+	return err
+}
+
+// Close flushes and closes the current capture file.
+func (r *captureRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	return r.closeFile()
+}
+
+// countingWriter counts the bytes written to the underlying writer
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// parseCaptureLine splits a line of a capture file into its receive timestamp
+// and the raw line. Lines without timestamp are returned unchanged.
+func parseCaptureLine(line string) (time.Time, string, bool) {
+	timestamp, raw, found := strings.Cut(line, "\t")
+	if !found {
+		return time.Time{}, line, false
+	}
+	received, err := time.Parse(captureTimeFormat, timestamp)
+	if err != nil {
+		return time.Time{}, line, false
+	}
+	return received, raw, true
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestCaptureRecorder_RotatesByDayAndSize(t *testing.T) {
+	dir := t.TempDir()
+	recorder, err := newCaptureRecorder(dir, "hargassner", 100, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	day1 := time.Date(2026, 2, 14, 23, 59, 58, 0, time.Local)
+	line := "pm 50 60 8.5 75 150 2.3 1.8 45.0 38.0 50.0 40.0\n"
+	for i := 0; i < 3; i++ {
+		if err := recorder.Record(day1.Add(time.Duration(i)*time.Millisecond), line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := recorder.Record(day1.Add(3*time.Second), "z 00:00:01 Kessel Aus\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.log"))
+	for i := range matches {
+		matches[i] = filepath.Base(matches[i])
+	}
+	sort.Strings(matches)
+	want := []string{
+		"hargassner-2026-02-14-001.log",
+		"hargassner-2026-02-14-002.log",
+		"hargassner-2026-02-15-001.log",
+	}
+	if len(matches) != len(want) {
+		t.Fatalf("expected files %v, got %v", want, matches)
+	}
+	for i := range want {
+		if matches[i] != want[i] {
+			t.Fatalf("expected files %v, got %v", want, matches)
+		}
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, want[2]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	received, raw, ok := parseCaptureLine(string(content))
+	if !ok {
+		t.Fatalf("captured line has no timestamp: %q", content)
+	}
+	if !received.Equal(day1.Add(3 * time.Second)) {
+		t.Fatalf("expected receive time %s, got %s", day1.Add(3*time.Second), received)
+	}
+	if raw != "z 00:00:01 Kessel Aus\n" {
+		t.Fatalf("unexpected raw line %q", raw)
+	}
+}
+
+func TestCaptureRecorder_CompressedCaptureCanBeReplayed(t *testing.T) {
+	dir := t.TempDir()
+	recorder, err := newCaptureRecorder(dir, "hargassner", 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	lines := []string{"pm 1 2 3\n", "z 14:10:40 Kessel Zündung\n", "pm 4 5 6\n"}
+	for i, line := range lines {
+		if err := recorder.Record(start.Add(time.Duration(i)*10*time.Millisecond), line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.log.gz"))
+	if len(matches) != 1 {
+		t.Fatalf("expected one compressed capture, got %v", matches)
+	}
+
+	reader := newSourceReader(&replaySource{path: matches[0], speed: 0})
+	reader.skipFirstLine = false
+	var replayed []string
+	if err := reader.Run(func(line string) { replayed = append(replayed, line) }); err != nil {
+		t.Fatal(err)
+	}
+	if len(replayed) != len(lines) {
+		t.Fatalf("expected %q, got %q", lines, replayed)
+	}
+	for i := range lines {
+		if replayed[i] != lines[i] {
+			t.Fatalf("line %d: expected %q, got %q", i, lines[i], replayed[i])
+		}
+	}
+}
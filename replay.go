@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pmInterval is the interval in which the boiler sends its pm records. A
+// capture without timestamps is replayed with this pace.
+const pmInterval = time.Second
+
+// replaySource replays a captured raw stream of the boiler. Captures written by
+// the captureRecorder are replayed with their recorded timing, gzip compressed
+// captures are decompressed transparently.
+type replaySource struct {
+	path string
+	// speed is the replay speed relative to real time. 0 replays as fast as possible.
+	speed float64
+	// clock follows the receive times of the capture, nil if not needed
+	clock *replayClock
+}
+
+func (s *replaySource) Open() (io.ReadCloser, error) {
+	file, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(s.path, ".gz") {
+		return newPacedReader(file, file, s.speed, s.clock), nil
+	}
+	decompressed, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return newPacedReader(decompressed, file, s.speed, s.clock), nil
+}
+
+func (s *replaySource) Reconnect() bool {
+	return false
+}
+
+func (s *replaySource) String() string {
+	return "file://" + s.path
+}
+
+// replayClock is the time of a replayed capture. It is the receive time of the
+// last delivered line plus the time passed since then at the replay speed, so
+// the z records are anchored to the day they were received and timeouts run
+// on the time of the capture. Without timestamps it is the host time.
+type replayClock struct {
+	mu    sync.Mutex
+	speed float64
+	// received is the receive time of the last delivered timestamped line
+	received  time.Time
+	delivered time.Time
+}
+
+func (c *replayClock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.received.IsZero() {
+		return time.Now()
+	}
+	return c.received.Add(time.Duration(float64(time.Since(c.delivered)) * c.speed))
+}
+
+func (c *replayClock) set(received time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.received = received
+	c.delivered = time.Now()
+}
+
+// pacedReader delivers the lines of a capture with the timing of the live stream.
+type pacedReader struct {
+	file    io.Closer
+	lines   *bufio.Reader
+	speed   float64
+	pending []byte
+	seenPm  bool
+	// lastReceived is the receive time of the previous timestamped line
+	lastReceived time.Time
+	// clock is set to the receive time of every delivered line, nil if unused
+	clock  *replayClock
+	closed chan struct{}
+}
+
+func newPacedReader(r io.Reader, file io.Closer, speed float64, clock *replayClock) *pacedReader {
+	return &pacedReader{
+		file:   file,
+		lines:  bufio.NewReader(r),
+		speed:  speed,
+		clock:  clock,
+		closed: make(chan struct{}),
+	}
+}
+
+func (r *pacedReader) Read(p []byte) (int, error) {
+	if len(r.pending) == 0 {
+		line, err := r.lines.ReadString('\n')
+		if line == "" {
+			return 0, err
+		}
+		line, err = r.pace(line)
+		if err != nil {
+			return 0, err
+		}
+		r.pending = []byte(line)
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// pace waits before a line is delivered and returns the raw line. Timestamped
+// lines are delayed by the recorded time since the previous line. Without
+// timestamps every pm record after the first one advances the time by pmInterval.
+func (r *pacedReader) pace(line string) (string, error) {
+	received, raw, timestamped := parseCaptureLine(line)
+
+	var delay time.Duration
+	switch {
+	case timestamped:
+		if !r.lastReceived.IsZero() {
+			delay = received.Sub(r.lastReceived)
+		}
+		r.lastReceived = received
+	case strings.HasPrefix(raw, "pm "):
+		if r.seenPm {
+			delay = pmInterval
+		}
+		r.seenPm = true
+	}
+
+	if r.speed > 0 && delay > 0 {
+		timer := time.NewTimer(time.Duration(float64(delay) / r.speed))
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-r.closed:
+			return "", io.ErrClosedPipe
+		}
+	}
+	if timestamped && r.clock != nil {
+		r.clock.set(received)
+	}
+	return raw, nil
+}
+
+func (r *pacedReader) Close() error {
+	select {
+	case <-r.closed:
+		return nil
+	default:
+		close(r.closed)
+	}
+	return r.file.Close()
+}
+
+// runReplay implements the replay subcommand. It feeds a captured raw stream
+// through the same processing as the live mode. The time of the boiler follows
+// the receive times of a capture with timestamps.
+func runReplay(args []string) {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	speed := flags.Float64("speed", 1, "replay speed relative to real time, 0 replays as fast as possible")
+	boilerID := flags.String("boiler", configuredBoilerIDs()[0], "ID of the boiler the capture file belongs to")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s replay [-speed factor] [-boiler id] <capture file>\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if *speed < 0 {
+		log.Fatalf("invalid replay speed %v", *speed)
+	}
+
+	log.Printf("Starting hargassner-monitor replay version %s (build %s, commit %s)", version, build, commit)
+
+	b, err := newConfiguredBoiler(*boilerID, false)
+	if err != nil {
+		log.Fatal(err)
+	}
+	source := &replaySource{path: flags.Arg(0), speed: *speed, clock: &replayClock{speed: *speed}}
+	b.now = source.clock.now
+	b.watchdog.now = source.clock.now
+	b.reader = newSourceReader(source)
+	b.reader.skipFirstLine = false
+
+	runMonitor([]*Boiler{b})
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReplaySource_FeedsAllLines(t *testing.T) {
+	capture := "pm 1 2\n" +
+		"z 14:10:40 Kessel Zündung\n" +
+		"pm 3 4\n" +
+		"pm 5 6"
+	path := filepath.Join(t.TempDir(), "capture.txt")
+	if err := os.WriteFile(path, []byte(capture), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	reader := newSourceReader(&replaySource{path: path, speed: 20})
+	reader.skipFirstLine = false
+
+	var lines []string
+	start := time.Now()
+	if err := reader.Run(func(line string) { lines = append(lines, line) }); err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	elapsed := time.Since(start)
+
+	want := []string{"pm 1 2\n", "z 14:10:40 Kessel Zündung\n", "pm 3 4\n", "pm 5 6"}
+	if len(lines) != len(want) {
+		t.Fatalf("expected %d lines, got %d: %q", len(want), len(lines), lines)
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Fatalf("line %d: got %q, want %q", i, lines[i], want[i])
+		}
+	}
+
+	// two pm intervals at 20x speed
+	if elapsed < 2*pmInterval/20 {
+		t.Fatalf("replay was not paced: took %s", elapsed)
+	}
+}
+
+func TestReplay_FollowsCaptureTime(t *testing.T) {
+	capture := "2025-12-24T23:59:50Z\tz 23:59:50 Kessel Zündung\n" +
+		"2025-12-25T00:08:10Z\tz 00:08:10 Kessel Leistungsbrand\n" +
+		"2025-12-25T02:00:00Z\tz 02:00:00 Kessel Aus\n" +
+		"2025-12-25T03:00:00Z\tz 03:00:00 Kessel Zündung\n"
+	path := filepath.Join(t.TempDir(), "capture.log")
+	if err := os.WriteFile(path, []byte(capture), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	b := newBoiler("replay-clock", "Replay Clock")
+	source := &replaySource{path: path, speed: 0, clock: &replayClock{}}
+	b.now = source.clock.now
+	b.watchdog.now = source.clock.now
+	reader := newSourceReader(source)
+	reader.skipFirstLine = false
+	if err := reader.Run(b.handleLine); err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+
+	kessel := b.kesselRecord
+	if kessel.DauerLetzteZuendung.Value != 500 || kessel.LetzteZuendung.Value != "2025-12-25T03:00:00Z" {
+		t.Fatalf("expected the Zündungen on the days of the capture, got %q, last lasting %d", kessel.LetzteZuendung.Value, kessel.DauerLetzteZuendung.Value)
+	}
+	if b.uhr.Abweichung.Value != 0 || b.uhr.Alarm.Value {
+		t.Fatalf("expected no clock offset in the replay, got %d", b.uhr.Abweichung.Value)
+	}
+	if b.statistik.day != "2025-12-25" || b.statistik.Gestern.ZuendungDauer.Value != 10 || b.statistik.Heute.ZuendungDauer.Value != 490 {
+		t.Fatalf("expected the statistics of the capture days, got day %s with %d and %d", b.statistik.day, b.statistik.Gestern.ZuendungDauer.Value, b.statistik.Heute.ZuendungDauer.Value)
+	}
+	// the ignition in progress times out on the time of the capture, not the host time
+	if b.fehlzuendung.checkTimeout(b.now(), b.now()) {
+		t.Fatalf("expected no ignition timeout at the end of the capture")
+	}
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand/v2"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// simPhase is an operating phase of the simulated boiler
+type simPhase int
+
+const (
+	simAus simPhase = iota
+	simZuendungStart
+	simZuendungEinschub
+	simZuendungPause
+	simZuendungReduziert
+	simLeistungsbrand
+	simEntaschung
+	simEntaschungRost
+	simStoerung
+)
+
+// simPhaseInfo describes the z events and the process values of a phase
+type simPhaseInfo struct {
+	// events are sent as z records when the phase is entered
+	events []string
+	// exitEvents are sent as z records when the phase is left
+	exitEvents []string
+	duration   time.Duration
+
+	// targets the process values approach during the phase
+	boilerTemperature  float64
+	exhaustTemperature float64
+	o2                 float64
+	primaryAirFan      float64
+	exhaustFan         float64
+	feedRate           float64
+	underpressure      float64
+	currentFeedScrew   float64
+	currentAsh         float64
+	currentRoom        float64
+}
+
+var simPhases = map[simPhase]simPhaseInfo{
+	simAus: {
+		events:   []string{"Kessel Aus"},
+		duration: 30 * time.Minute, boilerTemperature: 55, exhaustTemperature: 35, o2: 20.9,
+	},
+	simZuendungStart: {
+		events:   []string{"Kessel Zündung", "Kessel Zündung Start"},
+		duration: 100 * time.Second, boilerTemperature: 56, exhaustTemperature: 45, o2: 20.5,
+		primaryAirFan: 30, exhaustFan: 60, underpressure: 30,
+	},
+	simZuendungEinschub: {
+		events:   []string{"Kessel Zündung Einschub"},
+		duration: 3 * time.Minute, boilerTemperature: 57, exhaustTemperature: 70, o2: 17,
+		primaryAirFan: 30, exhaustFan: 60, feedRate: 30, underpressure: 30,
+		currentFeedScrew: 0.6, currentRoom: 0.4,
+	},
+	simZuendungPause: {
+		events:   []string{"Kessel Zündung Pause"},
+		duration: 2 * time.Minute, boilerTemperature: 58, exhaustTemperature: 90, o2: 15,
+		primaryAirFan: 40, exhaustFan: 60, underpressure: 30,
+	},
+	simZuendungReduziert: {
+		events:   []string{"Kessel Zündung Reduziert"},
+		duration: 3 * time.Minute, boilerTemperature: 62, exhaustTemperature: 110, o2: 12,
+		primaryAirFan: 50, exhaustFan: 70, feedRate: 20, underpressure: 35,
+		currentFeedScrew: 0.6, currentRoom: 0.4,
+	},
+	simLeistungsbrand: {
+		events:   []string{"Kessel Leistungsbrand"},
+		duration: 3 * time.Hour, boilerTemperature: 82, exhaustTemperature: 165, o2: 7.5,
+		primaryAirFan: 70, exhaustFan: 85, feedRate: 60, underpressure: 40,
+		currentFeedScrew: 0.6, currentRoom: 0.4,
+	},
+	simEntaschung: {
+		events:   []string{"Kessel Entaschung Start", "Kessel Entaschung Gebläse"},
+		duration: 10 * time.Minute, boilerTemperature: 80, exhaustTemperature: 120, o2: 16,
+		exhaustFan: 100, underpressure: 60,
+	},
+	simEntaschungRost: {
+		events:   []string{"Kessel Entaschung Rost"},
+		duration: 35 * time.Second, boilerTemperature: 78, exhaustTemperature: 100, o2: 19,
+		exhaustFan: 60, underpressure: 30, currentAsh: 1.2,
+	},
+	simStoerung: {
+		events:     []string{"Störung Set 10 Stop:1"},
+		exitEvents: []string{"Störung Quit 0010"},
+		duration:   5 * time.Minute, boilerTemperature: 55, exhaustTemperature: 40, o2: 20.9,
+	},
+}
+
+// boilerSimulator produces the serial output of a Hargassner boiler
+type boilerSimulator struct {
+	rand *rand.Rand
+	// clock is the time of the simulated boiler controller
+	clock time.Time
+	// step is the simulated time passing between two pm records
+	step time.Duration
+	// stoerungRate is the probability of an ignition ending in a Störung
+	stoerungRate float64
+
+	phase      simPhase
+	phaseStart time.Time
+
+	boilerTemperature  float64
+	exhaustTemperature float64
+	o2                 float64
+	outdoorTemperature float64
+	outdoorAverage     float64
+	flowTemperature1   float64
+	flowTemperature2   float64
+	boiler1Temperature float64
+}
+
+func newBoilerSimulator(start time.Time, speed float64, seed uint64) *boilerSimulator {
+	return &boilerSimulator{
+		rand:               rand.New(rand.NewPCG(seed, seed)),
+		clock:              start,
+		step:               time.Duration(float64(pmInterval) * speed),
+		stoerungRate:       0.1,
+		phase:              simAus,
+		phaseStart:         start,
+		boilerTemperature:  60,
+		exhaustTemperature: 40,
+		o2:                 20.9,
+		outdoorTemperature: 5,
+		outdoorAverage:     5,
+		flowTemperature1:   45,
+		flowTemperature2:   38,
+		boiler1Temperature: 52,
+	}
+}
+
+// tick advances the simulation by one pm interval and returns the lines the
+// boiler sends meanwhile.
+func (s *boilerSimulator) tick() []string {
+	s.clock = s.clock.Add(s.step)
+
+	var lines []string
+	if s.clock.Sub(s.phaseStart) >= simPhases[s.phase].duration {
+		for _, event := range simPhases[s.phase].exitEvents {
+			lines = append(lines, s.zRecord(event))
+		}
+		s.phase = s.nextPhase()
+		s.phaseStart = s.clock
+		for _, event := range simPhases[s.phase].events {
+			lines = append(lines, s.zRecord(event))
+		}
+	}
+
+	s.evolve()
+	return append(lines, s.pmRecord())
+}
+
+func (s *boilerSimulator) nextPhase() simPhase {
+	switch s.phase {
+	case simZuendungReduziert:
+		if s.rand.Float64() < s.stoerungRate {
+			return simStoerung
+		}
+		return simLeistungsbrand
+	case simEntaschungRost, simStoerung:
+		return simAus
+	default:
+		return s.phase + 1
+	}
+}
+
+// evolve moves the process values towards the targets of the current phase
+func (s *boilerSimulator) evolve() {
+	info := simPhases[s.phase]
+	s.boilerTemperature = s.approach(s.boilerTemperature, info.boilerTemperature, 15*time.Minute)
+	s.exhaustTemperature = s.approach(s.exhaustTemperature, info.exhaustTemperature, 3*time.Minute)
+	s.o2 = s.approach(s.o2, info.o2, time.Minute)
+	s.boiler1Temperature = s.approach(s.boiler1Temperature, s.boilerTemperature-5, time.Hour)
+	s.outdoorTemperature += s.rand.NormFloat64() * 0.01
+	s.outdoorAverage = s.approach(s.outdoorAverage, s.outdoorTemperature, 12*time.Hour)
+	s.flowTemperature1 = s.approach(s.flowTemperature1, 45, 10*time.Minute)
+	s.flowTemperature2 = s.approach(s.flowTemperature2, 38, 10*time.Minute)
+}
+
+// approach moves value towards target with the time constant tau and adds some noise
+func (s *boilerSimulator) approach(value, target float64, tau time.Duration) float64 {
+	factor := 1 - math.Exp(-s.step.Seconds()/tau.Seconds())
+	return value + (target-value)*factor + s.rand.NormFloat64()*0.05
+}
+
+func (s *boilerSimulator) noisy(value, deviation float64) float64 {
+	if value == 0 {
+		return 0
+	}
+	return math.Max(0, value+s.rand.NormFloat64()*deviation)
+}
+
+func (s *boilerSimulator) zRecord(event string) string {
+	return fmt.Sprintf("z %s %s\r\n", s.clock.Format("15:04:05"), event)
+}
+
+func (s *boilerSimulator) pmRecord() string {
+	info := simPhases[s.phase]
+	integer := func(v float64) string { return strconv.Itoa(int(math.Round(v))) }
+	decimal := func(v float64, digits int) string { return strconv.FormatFloat(v, 'f', digits, 64) }
+
+	underpressure := s.noisy(info.underpressure, 1)
+	fields := []string{
+		"pm",
+		integer(s.noisy(info.primaryAirFan, 1)), // 1 Primärluftgebläse
+		integer(s.noisy(info.exhaustFan, 1)),    // 2 Saugzuggebläse
+		decimal(s.o2, 1),                        // 3 O2 im Abgas
+		integer(s.boilerTemperature),            // 4 Kesseltemperatur
+		integer(s.exhaustTemperature),           // 5 Rauchgastemperatur
+		decimal(s.outdoorTemperature, 1),        // 6 Außentemperatur aktuell
+		decimal(s.outdoorAverage, 1),            // 7 Außentemperatur gemittelt
+		decimal(s.flowTemperature1, 1),          // 8 Vorlauf Heizkreis 1
+		decimal(s.flowTemperature2, 1),          // 9 Vorlauf Heizkreis 2
+		"45.0",                                  // 10 Vorlauf Soll Heizkreis 1
+		"38.0",                                  // 11 Vorlauf Soll Heizkreis 2
+		integer(s.boilerTemperature - 10),       // 12 Rücklauf
+		integer(s.boiler1Temperature),           // 13 Boiler 1
+		integer(s.noisy(info.feedRate, 0.5)),    // 14 Fördermenge
+		"75",                                    // 15 Kesselsolltemperatur
+		decimal(underpressure, 1),               // 16 Unterdruck aktuell
+		decimal(info.underpressure, 1),          // 17 Unterdruck gemittelt
+		decimal(info.underpressure, 1),          // 18 Unterdruck Soll
+		"0.0", "0.0", "0.0", "0.0",              // 19-22 Heizkreis 3 und 4
+		decimal(s.boiler1Temperature-2, 1), // 23 Boiler 2
+		"20.0", "20.0",                     // 24-25 FR25 Heizkreis 1 und 2
+		"0.0", "0.0", // 26-27 FR25 Heizkreis 3 und 4
+		"0", // 28
+		decimal(s.noisy(info.currentFeedScrew, 0.05), 2), // 29 Strom Einschub
+		decimal(s.noisy(info.currentAsh, 0.1), 2),        // 30 Strom Ascheaustragung
+		decimal(s.noisy(info.currentRoom, 0.05), 2),      // 31 Strom Raumaustragung
+	}
+
+	line := fields[0]
+	for _, field := range fields[1:] {
+		line += " " + field
+	}
+	return line + "\r\n"
+}
+
+// runSimulate implements the simulate subcommand. It writes the output of a
+// simulated boiler to a pseudo-terminal the monitor can read from.
+func runSimulate(args []string) {
+	flags := flag.NewFlagSet("simulate", flag.ExitOnError)
+	speed := flags.Float64("speed", 1, "simulated seconds per pm record")
+	link := flags.String("link", "", "create a symlink with this name pointing to the pseudo-terminal")
+	stoerungRate := flags.Float64("stoerung-rate", 0.1, "probability of an ignition ending in a Störung")
+	charsetName := flags.String("charset", "cp850", "code page of the simulated text output")
+	seed := flags.Uint64("seed", uint64(time.Now().UnixNano()), "seed of the random generator")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s simulate [options]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if *speed <= 0 {
+		log.Fatalf("invalid simulation speed %v", *speed)
+	}
+	charset, err := lookupCharset(*charsetName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	master, slave, err := openPty()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer master.Close()
+	defer slave.Close()
+
+	device := slave.Name()
+	if *link != "" {
+		os.Remove(*link)
+		if err := os.Symlink(device, *link); err != nil {
+			log.Fatalf("could not create link %s: %v", *link, err)
+		}
+		defer os.Remove(*link)
+		device = *link
+	}
+	log.Printf("Simulating Hargassner boiler, start the monitor with HARGASSNER_SERIAL_DEVICE=%s", device)
+
+	// discard everything the monitor might send
+	go io.Copy(io.Discard, master)
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+
+	simulator := newBoilerSimulator(time.Now(), *speed, *seed)
+	simulator.stoerungRate = *stoerungRate
+
+	ticker := time.NewTicker(pmInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			// like a real serial line we drop what nobody reads
+			discardStaleInput(slave)
+			for _, line := range simulator.tick() {
+				if _, err := io.WriteString(master, charset.encode(line)); err != nil {
+					log.Printf("could not write to pseudo-terminal: %v", err)
+					return
+				}
+			}
+		case <-sigs:
+			log.Println("Received signal, stopping simulator")
+			return
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBoilerSimulator_Cycle(t *testing.T) {
+	sim := newBoilerSimulator(time.Date(2026, 2, 14, 12, 0, 0, 0, time.Local), 10, 1)
+	sim.stoerungRate = 0
+
+	var events []string
+	var boilerTemperatureAtLeistungsbrand, o2AtLeistungsbrand float64
+	var boilerTemperatureAtEntaschung, o2AtEntaschung float64
+
+	for i := 0; i < 2000 && len(events) < 10; i++ {
+		for _, line := range sim.tick() {
+			fields := strings.Fields(line)
+			switch fields[0] {
+			case "z":
+				events = append(events, strings.Join(fields[2:], " "))
+			case "pm":
+				if len(fields) != 32 {
+					t.Fatalf("expected 32 fields in pm record, got %d: %q", len(fields), line)
+				}
+				record := newEmptyStatusRecord()
+				if _, err := record.bind(selectPmProfile(builtinPmProfiles, len(fields))); err != nil {
+					t.Fatal(err)
+				}
+				if err := parseStatusRecord(fields, record, testClock()); err != nil {
+					t.Fatalf("simulated pm record does not parse: %v", err)
+				}
+			}
+		}
+		boilerTemperature, o2 := sim.boilerTemperature, sim.o2
+		if len(events) > 0 {
+			switch events[len(events)-1] {
+			case "Kessel Leistungsbrand":
+				if boilerTemperatureAtLeistungsbrand == 0 {
+					boilerTemperatureAtLeistungsbrand, o2AtLeistungsbrand = boilerTemperature, o2
+				}
+			case "Kessel Entaschung Gebläse":
+				if boilerTemperatureAtEntaschung == 0 {
+					boilerTemperatureAtEntaschung, o2AtEntaschung = boilerTemperature, o2
+				}
+			}
+		}
+	}
+
+	want := []string{
+		"Kessel Zündung", "Kessel Zündung Start", "Kessel Zündung Einschub", "Kessel Zündung Pause",
+		"Kessel Zündung Reduziert", "Kessel Leistungsbrand", "Kessel Entaschung Start",
+		"Kessel Entaschung Gebläse", "Kessel Entaschung Rost", "Kessel Aus",
+	}
+	if strings.Join(events, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected events:\n got %q\nwant %q", events, want)
+	}
+
+	if boilerTemperatureAtEntaschung <= boilerTemperatureAtLeistungsbrand {
+		t.Fatalf("expected boiler temperature to rise during Leistungsbrand: %.1f -> %.1f",
+			boilerTemperatureAtLeistungsbrand, boilerTemperatureAtEntaschung)
+	}
+	if o2AtEntaschung >= o2AtLeistungsbrand {
+		t.Fatalf("expected O2 to drop during Leistungsbrand: %.1f -> %.1f", o2AtLeistungsbrand, o2AtEntaschung)
+	}
+}
+
+func TestBoilerSimulator_Stoerung(t *testing.T) {
+	sim := newBoilerSimulator(time.Date(2026, 2, 14, 12, 0, 0, 0, time.Local), 10, 1)
+	sim.stoerungRate = 1
+
+	var events []string
+	for i := 0; i < 1000; i++ {
+		for _, line := range sim.tick() {
+			if strings.HasPrefix(line, "z ") {
+				events = append(events, strings.Join(strings.Fields(line)[2:], " "))
+			}
+		}
+	}
+
+	joined := strings.Join(events, "|")
+	if !strings.Contains(joined, "Kessel Zündung Reduziert|Störung Set 10 Stop:1|Störung Quit 0010|Kessel Aus") {
+		t.Fatalf("expected Störung after ignition, got %q", events)
+	}
+}
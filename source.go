@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.bug.st/serial"
+)
+
+// LineSource is a byte stream delivering the records of the boiler line by line.
+//
+// Sources are selected by a URL in HARGASSNER_SERIAL_DEVICE:
+//   - serial:///dev/ttyUSB0 or a plain device path for a local serial port
+//   - tcp://host:port for a raw TCP socket (ser2net, ESP-Link, ...)
+type LineSource interface {
+	// Open opens a new connection to the source.
+	Open() (io.ReadCloser, error)
+	// Reconnect reports whether the source is reopened after the stream broke.
+	Reconnect() bool
+	String() string
+}
+
+// parseLineSource creates the LineSource for a source URL.
+func parseLineSource(spec string) (LineSource, error) {
+	scheme, rest, found := strings.Cut(spec, "://")
+	if !found {
+		return newSerialSource(spec), nil
+	}
+	switch scheme {
+	case "serial":
+		if rest == "" {
+			return nil, fmt.Errorf("missing serial device in %s", spec)
+		}
+		return newSerialSource(rest), nil
+	case "tcp":
+		host, port, err := net.SplitHostPort(rest)
+		if err != nil || host == "" || port == "" {
+			return nil, fmt.Errorf("invalid tcp address in %s: expected tcp://host:port", spec)
+		}
+		return &tcpSource{address: rest, dialTimeout: 10 * time.Second, readTimeout: time.Minute}, nil
+	default:
+		return nil, fmt.Errorf("unsupported source scheme %q in %s", scheme, spec)
+	}
+}
+
+type serialSource struct {
+	device string
+	mode   *serial.Mode
+}
+
+func newSerialSource(device string) *serialSource {
+	return &serialSource{
+		device: device,
+		mode: &serial.Mode{
+			BaudRate: 19200,
+			Parity:   serial.NoParity,
+			DataBits: 8,
+			StopBits: serial.OneStopBit,
+		},
+	}
+}
+
+func (s *serialSource) Open() (io.ReadCloser, error) {
+	return serial.Open(s.device, s.mode)
+}
+
+func (s *serialSource) Reconnect() bool {
+	return true
+}
+
+func (s *serialSource) String() string {
+	return "serial://" + s.device
+}
+
+type tcpSource struct {
+	address     string
+	dialTimeout time.Duration
+	// readTimeout closes connections that stay silent for too long. The boiler
+	// sends a pm record every second, so a silent socket is a dead socket.
+	readTimeout time.Duration
+}
+
+func (s *tcpSource) Open() (io.ReadCloser, error) {
+	conn, err := net.DialTimeout("tcp", s.address, s.dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return &deadlineConn{Conn: conn, timeout: s.readTimeout}, nil
+}
+
+func (s *tcpSource) Reconnect() bool {
+	return true
+}
+
+func (s *tcpSource) String() string {
+	return "tcp://" + s.address
+}
+
+// deadlineConn extends the read deadline of a connection before every read.
+type deadlineConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *deadlineConn) Read(p []byte) (int, error) {
+	if c.timeout > 0 {
+		if err := c.Conn.SetReadDeadline(time.Now().Add(c.timeout)); err != nil {
+			return 0, err
+		}
+	}
+	return c.Conn.Read(p)
+}
+
+// backoff computes exponentially growing delays between reconnect attempts.
+type backoff struct {
+	min     time.Duration
+	max     time.Duration
+	current time.Duration
+}
+
+func newBackoff(min, max time.Duration) *backoff {
+	if max < min {
+		max = min
+	}
+	return &backoff{min: min, max: max}
+}
+
+// next returns the delay before the next attempt and doubles it for the one after.
+func (b *backoff) next() time.Duration {
+	if b.current == 0 {
+		b.current = b.min
+	}
+	delay := b.current
+	b.current *= 2
+	if b.current > b.max {
+		b.current = b.max
+	}
+	return delay
+}
+
+func (b *backoff) reset() {
+	b.current = 0
+}
+
+var (
+	sourceConnectedGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "hargassner_source_connected",
+		Help: "Verbindung zur Heizung besteht (1) oder ist unterbrochen (0)",
+	}, []string{"source"})
+	sourceReconnects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "hargassner_source_reconnects_total",
+		Help: "Anzahl der Wiederverbindungen zur Heizung",
+	}, []string{"source"})
+	sourceConnectFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "hargassner_source_connect_failures_total",
+		Help: "Anzahl der fehlgeschlagenen Verbindungsversuche zur Heizung",
+	}, []string{"source"})
+)
+
+func init() {
+	prometheus.MustRegister(sourceConnectedGauge, sourceReconnects, sourceConnectFailures)
+}
+
+// sourceReader reads lines from a LineSource and reopens the source with
+// backoff if it supports reconnecting.
+type sourceReader struct {
+	source  LineSource
+	backoff *backoff
+
+	// onConnect and onDisconnect are called when the connection to the source
+	// is established or lost
+	onConnect    func()
+	onDisconnect func()
+
+	// recorder receives a copy of every raw line if capturing is enabled
+	recorder *captureRecorder
+
+	// skipFirstLine drops the first line of every connection because a live
+	// stream is usually joined in the middle of a line
+	skipFirstLine bool
+
+	mu     sync.Mutex
+	conn   io.ReadCloser
+	closed chan struct{}
+}
+
+func newSourceReader(source LineSource) *sourceReader {
+	return &sourceReader{
+		source:        source,
+		backoff:       newBackoff(time.Second, time.Minute),
+		skipFirstLine: true,
+		closed:        make(chan struct{}),
+	}
+}
+
+// Run reads lines until the source ends or the reader is closed. Each line
+// is passed to handle including its line terminator.
+func (r *sourceReader) Run(handle func(line string)) error {
+	name := r.source.String()
+	connected := sourceConnectedGauge.WithLabelValues(name)
+	opened := false
+
+	for {
+		conn, err := r.source.Open()
+		if err != nil {
+			if !r.source.Reconnect() {
+				return fmt.Errorf("could not open %s: %w", r.source, err)
+			}
+			sourceConnectFailures.WithLabelValues(name).Inc()
+			delay := r.backoff.next()
+			log.Printf("could not open %s: %v (retrying in %s)", r.source, err, delay)
+			if !r.wait(delay) {
+				return nil
+			}
+			continue
+		}
+		if !r.setConn(conn) {
+			conn.Close()
+			return nil
+		}
+		if opened {
+			sourceReconnects.WithLabelValues(name).Inc()
+			log.Printf("reconnected to %s", r.source)
+		}
+		opened = true
+		connected.Set(1)
+		if r.onConnect != nil {
+			r.onConnect()
+		}
+
+		err = r.readLines(conn, handle)
+		conn.Close()
+		connected.Set(0)
+		if r.onDisconnect != nil {
+			r.onDisconnect()
+		}
+		if r.isClosed() {
+			return nil
+		}
+		if !r.source.Reconnect() {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		delay := r.backoff.next()
+		log.Printf("connection to %s lost: %v (reconnecting in %s)", r.source, err, delay)
+		if !r.wait(delay) {
+			return nil
+		}
+	}
+}
+
+func (r *sourceReader) readLines(conn io.Reader, handle func(line string)) error {
+	reader := bufio.NewReader(conn)
+
+	if r.skipFirstLine {
+		if _, err := reader.ReadString('\n'); err != nil {
+			return err
+		}
+	}
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			if line != "" && errors.Is(err, io.EOF) && !r.source.Reconnect() {
+				// the last line of a finite source may lack its terminator
+				handle(line)
+			}
+			return err
+		}
+		r.backoff.reset()
+		if r.recorder != nil {
+			if err := r.recorder.Record(time.Now(), line); err != nil {
+				log.Printf("could not record line: %v", err)
+			}
+		}
+		handle(line)
+	}
+}
+
+func (r *sourceReader) setConn(conn io.ReadCloser) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.isClosed() {
+		return false
+	}
+	r.conn = conn
+	return true
+}
+
+// wait sleeps for delay and reports false if the reader was closed meanwhile.
+func (r *sourceReader) wait(delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-r.closed:
+		return false
+	}
+}
+
+func (r *sourceReader) isClosed() bool {
+	select {
+	case <-r.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close stops the reader and closes the current connection and the recorder.
+func (r *sourceReader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.isClosed() {
+		return nil
+	}
+	close(r.closed)
+	if r.recorder != nil {
+		if err := r.recorder.Close(); err != nil {
+			log.Printf("could not close capture file: %v", err)
+		}
+	}
+	if r.conn != nil {
+		return r.conn.Close()
+	}
+	return nil
+}
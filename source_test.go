@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestParseLineSource(t *testing.T) {
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"/dev/ttyUSB0", "serial:///dev/ttyUSB0"},
+		{"serial:///dev/ttyUSB1", "serial:///dev/ttyUSB1"},
+		{"tcp://ser2net.local:4001", "tcp://ser2net.local:4001"},
+	}
+	for _, tt := range tests {
+		source, err := parseLineSource(tt.spec)
+		if err != nil {
+			t.Fatalf("parseLineSource(%q) failed: %v", tt.spec, err)
+		}
+		if source.String() != tt.want {
+			t.Fatalf("parseLineSource(%q) = %s, want %s", tt.spec, source, tt.want)
+		}
+	}
+
+	for _, spec := range []string{"tcp://ser2net.local", "udp://host:1", "serial://"} {
+		if _, err := parseLineSource(spec); err == nil {
+			t.Fatalf("expected error for %q", spec)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	b := newBackoff(time.Second, 5*time.Second)
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, w := range want {
+		if got := b.next(); got != w {
+			t.Fatalf("delay %d: got %s, want %s", i, got, w)
+		}
+	}
+	b.reset()
+	if got := b.next(); got != time.Second {
+		t.Fatalf("after reset expected %s, got %s", time.Second, got)
+	}
+}
+
+func TestSourceReader_TCPReconnect(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	// Every connection starts with a partial line which must be skipped
+	go func() {
+		for _, payload := range []string{"1 2 3\npm first\n", "rung Quit 7\nz second\n"} {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte(payload))
+			conn.Close()
+		}
+	}()
+
+	source, err := parseLineSource("tcp://" + listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := newSourceReader(source)
+	reader.backoff = newBackoff(10*time.Millisecond, 10*time.Millisecond)
+
+	lines := make(chan string, 10)
+	go reader.Run(func(line string) { lines <- line })
+	defer reader.Close()
+
+	for _, want := range []string{"pm first\n", "z second\n"} {
+		select {
+		case got := <-lines:
+			if got != want {
+				t.Fatalf("got line %q, want %q", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for %q", want)
+		}
+	}
+
+	if got := testutil.ToFloat64(sourceReconnects.WithLabelValues(source.String())); got != 1 {
+		t.Fatalf("expected 1 reconnect, got %v", got)
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// stateFile persists the counters and the last known state of the boilers
+// across restarts, e.g. in a Docker volume. The file holds the state of all
+// boilers keyed by their ID.
+type stateFile struct {
+	path string
+	// modTime is the modification time of the file when it was loaded
+	modTime time.Time
+	mu      sync.Mutex
+	boilers map[string]*boilerState
+}
+
+// boilerState is the persisted state of a boiler
+type boilerState struct {
+	// Values holds the values of the persisted status fields keyed by "<node>/<id>"
+	Values map[string]json.RawMessage `json:"values"`
+	// Updated holds the times the values were received keyed like Values
+	Updated map[string]time.Time `json:"updated,omitempty"`
+	// the start times of the phases in progress
+	ZuendungStart       *time.Time      `json:"zuendungStart,omitempty"`
+	Zuendung            *zuendungState  `json:"zuendung,omitempty"`
+	LeistungsbrandStart *time.Time      `json:"leistungsbrandStart,omitempty"`
+	EntaschungStart     *time.Time      `json:"entaschungStart,omitempty"`
+	KesselZustand       kesselZustand   `json:"kesselZustand,omitempty"`
+	Statistik           *statistikState `json:"statistik,omitempty"`
+}
+
+// persistentField is a status field whose value survives a restart
+type persistentField interface {
+	persist() (value any, updated time.Time, ok bool)
+	restore(data json.RawMessage, updated time.Time) error
+	// parse sets the field from a published value
+	parse(value string) error
+}
+
+// persist returns the value of the field and the time it was received, ok is
+// false if no value was received yet
+func (field *StatusField[T]) persist() (any, time.Time, bool) {
+	return field.Value, field.Updated, !field.Updated.IsZero()
+}
+
+// restore sets the field to a persisted value received at updated
+func (field *StatusField[T]) restore(data json.RawMessage, updated time.Time) error {
+	var value T
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	field.SetValue(value)
+	field.Updated = updated
+	return nil
+}
+
+// loadStateFile reads the state file at path. A missing file is an empty state.
+func loadStateFile(path string) (*stateFile, error) {
+	s := &stateFile{path: path, boilers: make(map[string]*boilerState)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(path); err == nil {
+		s.modTime = info.ModTime()
+	}
+	if err := json.Unmarshal(data, &s.boilers); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// persistentFields returns the status fields of the boiler which are
+// persisted, keyed by "<node>/<id>".
+func (b *Boiler) persistentFields() map[string]persistentField {
+	kessel := b.kesselRecord
+	entaschung := b.entaschung
+	stoerung := b.stoerungRecord
+	fehlzuendung := b.fehlzuendung
+	fields := []*StatusField[int]{
+		&kessel.AnzahlZuendungen, &kessel.DauerLetzteZuendung, &kessel.DauerLetzterLeistungsbrand,
+		&fehlzuendung.AnzahlFehlzuendungen, &fehlzuendung.AnzahlErfolgreicheZuendungen,
+		&entaschung.AnzahlEntaschungen, &entaschung.DauerLetzteEntaschung,
+		&stoerung.StoerungNr,
+	}
+	for _, phase := range zuendungPhasen {
+		fields = append(fields, kessel.DauerZuendungPhase[phase])
+	}
+	for _, schritt := range entaschungSchritte {
+		fields = append(fields, entaschung.DauerSchritt[schritt.step])
+	}
+	persistent := make(map[string]persistentField)
+	for _, field := range fields {
+		persistent[field.Node+"/"+field.Id] = field
+	}
+	for _, field := range []*StatusField[string]{
+		&kessel.LetzteZuendung, &kessel.LetzterLeistungsbrand, &kessel.Zustand, &kessel.ZustandSeit,
+		&entaschung.LetzteEntaschung,
+		&fehlzuendung.LetzteFehlzuendung, &fehlzuendung.Grund,
+		&stoerung.StoerungText, &stoerung.LastActive,
+	} {
+		persistent[field.Node+"/"+field.Id] = field
+	}
+	persistent[entaschung.MaxStromAscheaustragung.Node+"/"+entaschung.MaxStromAscheaustragung.Id] = &entaschung.MaxStromAscheaustragung
+	persistent[fehlzuendung.Quote.Node+"/"+fehlzuendung.Quote.Id] = &fehlzuendung.Quote
+	persistent[stoerung.StoerungActive.Node+"/"+stoerung.StoerungActive.Id] = &stoerung.StoerungActive
+	return persistent
+}
+
+// restore sets the boiler to its persisted state. It returns false if the
+// file holds no state of the boiler. The caller must hold b.mu.
+func (s *stateFile) restore(b *Boiler) bool {
+	s.mu.Lock()
+	state := s.boilers[b.ID]
+	s.mu.Unlock()
+	if state == nil {
+		return false
+	}
+
+	fields := b.persistentFields()
+	for key, data := range state.Values {
+		field, ok := fields[key]
+		if !ok {
+			log.Printf("Ignoring unknown field %s in state of %s", key, b.ID)
+			continue
+		}
+		// the values of older files have no receive time, they are at least
+		// as old as the file
+		updated, ok := state.Updated[key]
+		if !ok {
+			updated = s.modTime
+		}
+		if err := field.restore(data, updated); err != nil {
+			log.Printf("could not restore %s of %s: %v", key, b.ID, err)
+		}
+	}
+
+	kessel := b.kesselRecord
+	if state.ZuendungStart != nil {
+		kessel.lastZuendungStart = *state.ZuendungStart
+	}
+	if state.Zuendung != nil {
+		kessel.restoreZuendung(state.Zuendung)
+	}
+	if state.LeistungsbrandStart != nil {
+		kessel.lastLeistungsbrandStart = *state.LeistungsbrandStart
+	}
+	if state.EntaschungStart != nil {
+		b.entaschung.start = *state.EntaschungStart
+		b.entaschung.dauer = make(map[string]time.Duration)
+	}
+	if state.KesselZustand != "" {
+		kessel.kesselZustand = state.KesselZustand
+	}
+	// an ignition in progress awaits its outcome, its timeout counts from the
+	// restart as the receive time is unknown
+	if kessel.kesselZustand == zustandZuendung {
+		b.fehlzuendung.zuendung(b.now())
+	}
+	kessel.stoerung = b.stoerungRecord.StoerungActive.Value
+	if kessel.Zustand.Value != "" {
+		kessel.publishZustand(kesselZustand(kessel.Zustand.Value))
+	}
+	if state.Statistik != nil {
+		b.statistik.restore(state.Statistik)
+		b.statistik.rollover(b.now())
+	}
+	log.Printf("Restored state of %s from %s", b.ID, s.path)
+	return true
+}
+
+// save writes the state of the boiler to the file. The caller must hold b.mu.
+func (s *stateFile) save(b *Boiler) error {
+	state := &boilerState{Values: make(map[string]json.RawMessage), Updated: make(map[string]time.Time)}
+	for key, field := range b.persistentFields() {
+		value, updated, ok := field.persist()
+		if !ok {
+			continue
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		state.Values[key] = data
+		state.Updated[key] = updated
+	}
+	optionalTime := func(t time.Time) *time.Time {
+		if t.IsZero() {
+			return nil
+		}
+		return &t
+	}
+	state.ZuendungStart = optionalTime(b.kesselRecord.lastZuendungStart)
+	state.Zuendung = b.kesselRecord.persistZuendung()
+	state.LeistungsbrandStart = optionalTime(b.kesselRecord.lastLeistungsbrandStart)
+	state.EntaschungStart = optionalTime(b.entaschung.start)
+	state.KesselZustand = b.kesselRecord.kesselZustand
+	state.Statistik = b.statistik.persist()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.boilers[b.ID] = state
+	data, err := json.MarshalIndent(s.boilers, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data)
+}
+
+// writeFileAtomic replaces the file at path with data. The data is written to
+// a temporary file first, so a crash leaves either the old or the new file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// restoreState restores the persisted state of the boiler if a state file is
+// configured. It returns true if a persisted state was restored.
+func (b *Boiler) restoreState() bool {
+	if b.state == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state.restore(b)
+}
+
+// saveState persists the state of the boiler if a state file is configured.
+// The caller must hold b.mu.
+func (b *Boiler) saveState() {
+	if b.state == nil {
+		return
+	}
+	if err := b.state.save(b); err != nil {
+		log.Printf("could not save state of %s to %s: %v", b.ID, b.state.path, err)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestStateFile_SaveAndRestore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	state, err := loadStateFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := newBoiler("state", "State")
+	b.now = testClock
+	b.state = state
+	for _, line := range []string{
+		"z 14:10:40 Kessel Zündung",
+		"z 14:20:20 Kessel Leistungsbrand",
+		"z 14:50:00 Störung Set 7 Stop:1",
+	} {
+		b.processLine(line)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected only the state file, got %v", entries)
+	}
+
+	// restart
+	state, err = loadStateFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := newBoiler("state", "State")
+	restored.now = testClock
+	restored.state = state
+	if !restored.restoreState() {
+		t.Fatalf("expected the state to be restored")
+	}
+	other := newBoiler("state-other", "State Other")
+	other.state = state
+	if other.restoreState() {
+		t.Fatalf("expected no state of another boiler")
+	}
+
+	kessel := restored.kesselRecord
+	if kessel.AnzahlZuendungen.Value != 1 || kessel.DauerLetzteZuendung.Value != 580 {
+		t.Fatalf("unexpected restored counters %d %d", kessel.AnzahlZuendungen.Value, kessel.DauerLetzteZuendung.Value)
+	}
+	if got := testutil.ToFloat64(kessel.AnzahlZuendungen.PromGauge); got != 1 {
+		t.Fatalf("expected restored gauge 1, got %v", got)
+	}
+	if !restored.stoerungRecord.StoerungActive.Value || restored.stoerungRecord.StoerungNr.Value != 7 {
+		t.Fatalf("expected restored Störung 7")
+	}
+	if restored.statistik.Heute.Zuendungen.Value != 1 || restored.statistik.Heute.Stoerungen.Value != 1 {
+		t.Fatalf("expected restored statistics, got %d %d", restored.statistik.Heute.Zuendungen.Value, restored.statistik.Heute.Stoerungen.Value)
+	}
+	if kessel.Zustand.Value != string(zustandStoerung) {
+		t.Fatalf("expected restored state stoerung, got %q", kessel.Zustand.Value)
+	}
+
+	// the phase in progress before the restart ends after it
+	restored.processLine("z 18:00:32 Störung Quit 0007")
+	if kessel.Zustand.Value != string(zustandLeistungsbrand) {
+		t.Fatalf("expected state leistungsbrand after Quit, got %q", kessel.Zustand.Value)
+	}
+	restored.processLine("z 18:00:32 Kessel Aus")
+	if kessel.DauerLetzterLeistungsbrand.Value != 13212 {
+		t.Fatalf("expected DauerLetzterLeistungsbrand 13212, got %d", kessel.DauerLetzterLeistungsbrand.Value)
+	}
+	restored.processLine("z 18:05:00 Kessel Zündung")
+	if kessel.AnzahlZuendungen.Value != 2 {
+		t.Fatalf("expected AnzahlZuendungen to continue with 2, got %d", kessel.AnzahlZuendungen.Value)
+	}
+}
+
+func TestLoadStateFile_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadStateFile(path); err == nil {
+		t.Fatalf("expected error for invalid state file")
+	}
+}
+
+func TestStateFile_RestoresReceiveTimesAndZuendungPhase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	state, err := loadStateFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := newBoiler("state-phase", "State Phase")
+	b.now = testClock
+	b.state = state
+	b.processLine("z 14:10:40 Kessel Zündung")
+	b.processLine("z 14:12:20 Kessel Zündung Einschub")
+	updated := b.kesselRecord.AnzahlZuendungen.Updated
+
+	// restart during the Einschub phase
+	state, err = loadStateFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := newBoiler("state-phase", "State Phase")
+	restored.now = testClock
+	restored.state = state
+	time.Sleep(10 * time.Millisecond)
+	restored.restoreState()
+
+	kessel := restored.kesselRecord
+	if !kessel.AnzahlZuendungen.Updated.Equal(updated) {
+		t.Fatalf("expected the receive time %s of the restored value, got %s", updated, kessel.AnzahlZuendungen.Updated)
+	}
+	restored.processLine("z 14:15:00 Kessel Zündung Pause")
+	restored.processLine("z 14:20:20 Kessel Leistungsbrand")
+	if einschub, pause := kessel.DauerZuendungPhase["Einschub"].Value, kessel.DauerZuendungPhase["Pause"].Value; einschub != 160 || pause != 320 {
+		t.Fatalf("expected the phases to continue after the restart, got Einschub %d and Pause %d", einschub, pause)
+	}
+}
+
+func TestStateFile_RestoresWithoutReceiveTimes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte(`{"legacy": {"values": {"kessel/AnzahlZuendungen": 5}}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Date(2026, 2, 13, 8, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	state, err := loadStateFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := newBoiler("legacy", "Legacy")
+	b.now = testClock
+	b.state = state
+	b.restoreState()
+
+	// the values of a file without receive times are as old as the file
+	if field := b.kesselRecord.AnzahlZuendungen; field.Value != 5 || !field.Updated.Equal(modTime) {
+		t.Fatalf("expected 5 received at %s, got %d at %s", modTime, field.Value, field.Updated)
+	}
+}
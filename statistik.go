@@ -0,0 +1,251 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/creativeprojects/go-homie"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// burnStats are the aggregates of the z events of a period
+type burnStats struct {
+	Zuendungen             int     `json:"zuendungen"`
+	ZuendungSekunden       float64 `json:"zuendungSekunden"`
+	LeistungsbrandSekunden float64 `json:"leistungsbrandSekunden"`
+	Entaschungen           int     `json:"entaschungen"`
+	Stoerungen             int     `json:"stoerungen"`
+}
+
+func (s *burnStats) add(delta burnStats) {
+	s.Zuendungen += delta.Zuendungen
+	s.ZuendungSekunden += delta.ZuendungSekunden
+	s.LeistungsbrandSekunden += delta.LeistungsbrandSekunden
+	s.Entaschungen += delta.Entaschungen
+	s.Stoerungen += delta.Stoerungen
+}
+
+var (
+	zuendungenTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "hargassner_zuendungen_total",
+		Help: "Anzahl der Zündungen",
+	}, []string{"boiler"})
+	zuendungSecondsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "hargassner_zuendung_seconds_total",
+		Help: "Gesamtdauer der Zündungen",
+	}, []string{"boiler"})
+	leistungsbrandSecondsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "hargassner_leistungsbrand_seconds_total",
+		Help: "Gesamtdauer des Leistungsbrands",
+	}, []string{"boiler"})
+	entaschungenTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "hargassner_entaschungen_total",
+		Help: "Anzahl der Entaschungen",
+	}, []string{"boiler"})
+	stoerungenTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "hargassner_stoerungen_total",
+		Help: "Anzahl der Störungen",
+	}, []string{"boiler"})
+)
+
+func init() {
+	prometheus.MustRegister(zuendungenTotal, zuendungSecondsTotal, leistungsbrandSecondsTotal, entaschungenTotal, stoerungenTotal)
+}
+
+// statistikFields publishes the aggregates of a period with the ID prefix of the period
+type statistikFields struct {
+	Zuendungen            StatusField[int]
+	ZuendungDauer         StatusField[int]
+	LeistungsbrandStunden StatusField[float64]
+	Entaschungen          StatusField[int]
+	Stoerungen            StatusField[int]
+}
+
+func newStatistikFields(node *homie.Node, boilerID, prefix string, name MultiLanguageString) *statistikFields {
+	ret := &statistikFields{
+		Zuendungen:            StatusField[int]{Id: prefix + "Zuendungen", Name: MultiLanguageString{EN: "Ignitions " + name.EN, DE: "Zündungen " + name.DE}, Unit: ""},
+		ZuendungDauer:         StatusField[int]{Id: prefix + "ZuendungDauer", Name: MultiLanguageString{EN: "Ignition Time " + name.EN, DE: "Dauer Zündungen " + name.DE}, Unit: "s"},
+		LeistungsbrandStunden: StatusField[float64]{Id: prefix + "LeistungsbrandStunden", Name: MultiLanguageString{EN: "Power Fire Hours " + name.EN, DE: "Stunden Leistungsbrand " + name.DE}, Unit: "h"},
+		Entaschungen:          StatusField[int]{Id: prefix + "Entaschungen", Name: MultiLanguageString{EN: "Ash Removals " + name.EN, DE: "Entaschungen " + name.DE}, Unit: ""},
+		Stoerungen:            StatusField[int]{Id: prefix + "Stoerungen", Name: MultiLanguageString{EN: "Errors " + name.EN, DE: "Störungen " + name.DE}, Unit: ""},
+	}
+
+	registerStatusField(&ret.Zuendungen, node, "statistik", boilerID)
+	registerStatusField(&ret.ZuendungDauer, node, "statistik", boilerID)
+	registerStatusField(&ret.LeistungsbrandStunden, node, "statistik", boilerID)
+	registerStatusField(&ret.Entaschungen, node, "statistik", boilerID)
+	registerStatusField(&ret.Stoerungen, node, "statistik", boilerID)
+
+	return ret
+}
+
+func (f *statistikFields) publish(stats burnStats) {
+	f.Zuendungen.SetValue(stats.Zuendungen)
+	f.ZuendungDauer.SetValue(int(stats.ZuendungSekunden))
+	f.LeistungsbrandStunden.SetValue(math.Round(stats.LeistungsbrandSekunden/36) / 100)
+	f.Entaschungen.SetValue(stats.Entaschungen)
+	f.Stoerungen.SetValue(stats.Stoerungen)
+}
+
+func (f *statistikFields) statusValues(now time.Time) []StatusValue {
+	return []StatusValue{
+		f.Zuendungen.status(now), f.ZuendungDauer.status(now), f.LeistungsbrandStunden.status(now),
+		f.Entaschungen.status(now), f.Stoerungen.status(now),
+	}
+}
+
+func (f *statistikFields) properties() []*homie.Property {
+	return []*homie.Property{
+		f.Zuendungen.HomieProperty, f.ZuendungDauer.HomieProperty, f.LeistungsbrandStunden.HomieProperty,
+		f.Entaschungen.HomieProperty, f.Stoerungen.HomieProperty,
+	}
+}
+
+// StatistikRecord aggregates the z events per day and per heating season
+// (July to June). The day rolls over at local midnight of the host clock, the
+// boiler clock may be off.
+type StatistikRecord struct {
+	Heute   *statistikFields
+	Gestern *statistikFields
+	Saison  *statistikFields
+	// SaisonName is the heating season, e.g. "2025/26"
+	SaisonName StatusField[string]
+
+	boilerID string
+	// day is the current day ("2006-01-02") of heute
+	day     string
+	season  string
+	heute   burnStats
+	gestern burnStats
+	saison  burnStats
+}
+
+func newEmptyStatistikRecord(node *homie.Node, boilerID string) *StatistikRecord {
+	ret := &StatistikRecord{
+		Heute:      newStatistikFields(node, boilerID, "heute", MultiLanguageString{EN: "Today", DE: "heute"}),
+		Gestern:    newStatistikFields(node, boilerID, "gestern", MultiLanguageString{EN: "Yesterday", DE: "gestern"}),
+		Saison:     newStatistikFields(node, boilerID, "saison", MultiLanguageString{EN: "Season", DE: "Saison"}),
+		SaisonName: StatusField[string]{Id: "saison", Name: MultiLanguageString{EN: "Heating Season", DE: "Heizsaison"}, Unit: ""},
+		boilerID:   boilerID,
+	}
+	registerStatusField(&ret.SaisonName, node, "statistik", boilerID)
+	return ret
+}
+
+// properties returns the Homie properties of the statistics
+func (r *StatistikRecord) properties() []*homie.Property {
+	properties := []*homie.Property{r.SaisonName.HomieProperty}
+	for _, fields := range []*statistikFields{r.Heute, r.Gestern, r.Saison} {
+		properties = append(properties, fields.properties()...)
+	}
+	return properties
+}
+
+// statistikState is the persisted state of the statistics
+type statistikState struct {
+	Day     string    `json:"day"`
+	Season  string    `json:"season"`
+	Heute   burnStats `json:"heute"`
+	Gestern burnStats `json:"gestern"`
+	Saison  burnStats `json:"saison"`
+}
+
+func (r *StatistikRecord) persist() *statistikState {
+	if r.day == "" {
+		return nil
+	}
+	return &statistikState{Day: r.day, Season: r.season, Heute: r.heute, Gestern: r.gestern, Saison: r.saison}
+}
+
+func (r *StatistikRecord) restore(state *statistikState) {
+	r.day, r.season = state.Day, state.Season
+	r.heute, r.gestern, r.saison = state.Heute, state.Gestern, state.Saison
+	r.publish()
+}
+
+// heatingSeason returns the heating season of t, which starts in July
+func heatingSeason(t time.Time) string {
+	year := t.Year()
+	if t.Month() < time.July {
+		year--
+	}
+	return fmt.Sprintf("%d/%02d", year, (year+1)%100)
+}
+
+// rollover starts a new day and season when now is past the current ones.
+// The aggregates of today become the ones of yesterday.
+func (r *StatistikRecord) rollover(now time.Time) {
+	day := now.Format(time.DateOnly)
+	if day <= r.day {
+		return
+	}
+	if timestampDay(day, -1) == r.day {
+		r.gestern = r.heute
+	} else {
+		r.gestern = burnStats{}
+	}
+	r.heute = burnStats{}
+	r.day = day
+	if season := heatingSeason(now); season != r.season {
+		r.season = season
+		r.saison = burnStats{}
+	}
+	r.publish()
+}
+
+func (r *StatistikRecord) publish() {
+	r.Heute.publish(r.heute)
+	r.Gestern.publish(r.gestern)
+	r.Saison.publish(r.saison)
+	r.SaisonName.SetValue(r.season)
+}
+
+// add adds the aggregates of an event at timestamp, received at the host time
+// received, to the periods containing it. Only the host time rolls the day
+// over, events of a day which has not yet begun on the host, e.g. from a boiler
+// clock running ahead, count to today.
+func (r *StatistikRecord) add(received, timestamp time.Time, delta burnStats) {
+	r.rollover(received)
+	day := timestamp.Format(time.DateOnly)
+	today := day >= r.day
+	if today {
+		r.heute.add(delta)
+	} else if day == timestampDay(r.day, -1) {
+		r.gestern.add(delta)
+	}
+	if today || heatingSeason(timestamp) == r.season {
+		r.saison.add(delta)
+	}
+	r.publish()
+
+	zuendungenTotal.WithLabelValues(r.boilerID).Add(float64(delta.Zuendungen))
+	zuendungSecondsTotal.WithLabelValues(r.boilerID).Add(delta.ZuendungSekunden)
+	leistungsbrandSecondsTotal.WithLabelValues(r.boilerID).Add(delta.LeistungsbrandSekunden)
+	entaschungenTotal.WithLabelValues(r.boilerID).Add(float64(delta.Entaschungen))
+	stoerungenTotal.WithLabelValues(r.boilerID).Add(float64(delta.Stoerungen))
+}
+
+// timestampDay returns the day offset by days from day ("2006-01-02")
+func timestampDay(day string, days int) string {
+	t, err := time.Parse(time.DateOnly, day)
+	if err != nil {
+		return ""
+	}
+	return t.AddDate(0, 0, days).Format(time.DateOnly)
+}
+
+// addDuration adds the duration of a phase from start to end, received at the
+// host time received, split at midnight to the days it covers. duration
+// returns the aggregates of a part.
+func (r *StatistikRecord) addDuration(received, start, end time.Time, duration func(seconds float64) burnStats) {
+	for start.Before(end) {
+		midnight := time.Date(start.Year(), start.Month(), start.Day()+1, 0, 0, 0, 0, start.Location())
+		partEnd := end
+		if midnight.Before(end) {
+			partEnd = midnight
+		}
+		r.add(received, start, duration(partEnd.Sub(start).Seconds()))
+		start = partEnd
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestHeatingSeason(t *testing.T) {
+	for date, want := range map[time.Time]string{
+		time.Date(2026, 2, 14, 12, 0, 0, 0, time.UTC):   "2025/26",
+		time.Date(2026, 6, 30, 23, 59, 59, 0, time.UTC): "2025/26",
+		time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC):     "2026/27",
+		time.Date(2099, 12, 1, 0, 0, 0, 0, time.UTC):    "2099/00",
+	} {
+		if got := heatingSeason(date); got != want {
+			t.Errorf("heating season of %s: expected %s, got %s", date, want, got)
+		}
+	}
+}
+
+func TestStatistik(t *testing.T) {
+	b := newBoiler("statistik", "Statistik")
+	now := time.Date(2026, 2, 14, 16, 0, 0, 0, time.UTC)
+	b.now = func() time.Time { return now }
+	statistik := b.statistik
+
+	for _, line := range []string{
+		"z 14:10:40 Kessel Zündung",
+		"z 14:20:20 Kessel Leistungsbrand",
+		"z 17:50:18 Kessel Entaschung Start",
+		"z 17:59:58 Kessel Entaschung Rost",
+		"z 18:00:32 Kessel Aus",
+		"z 18:39:41 Störung Set 10 Stop:1",
+		"z 18:40:16 Störung Quit 0010",
+	} {
+		b.processLine(line)
+	}
+	// the second Leistungsbrand lasts from 23:00 to 01:00
+	now = time.Date(2026, 2, 14, 23, 0, 0, 0, time.UTC)
+	b.processLine("z 23:00:00 Kessel Leistungsbrand")
+
+	if got := statistik.Heute.Zuendungen.Value; got != 1 {
+		t.Fatalf("expected 1 Zündung today, got %d", got)
+	}
+	if got := statistik.Heute.ZuendungDauer.Value; got != 580 {
+		t.Fatalf("expected Zündung time 580s today, got %d", got)
+	}
+	// 14:20:20 bis 18:00:32 sind 13212 Sekunden
+	if got := statistik.Heute.LeistungsbrandStunden.Value; got != 3.67 {
+		t.Fatalf("expected 3.67 Leistungsbrand hours today, got %v", got)
+	}
+	if statistik.Heute.Entaschungen.Value != 1 || statistik.Heute.Stoerungen.Value != 1 {
+		t.Fatalf("expected 1 Entaschung and 1 Störung today, got %d %d", statistik.Heute.Entaschungen.Value, statistik.Heute.Stoerungen.Value)
+	}
+
+	// local midnight
+	now = time.Date(2026, 2, 15, 0, 0, 1, 0, time.UTC)
+	b.rolloverStatistik()
+	if statistik.Heute.Zuendungen.Value != 0 || statistik.Gestern.Zuendungen.Value != 1 {
+		t.Fatalf("expected the values of today as yesterday after midnight, got %d %d", statistik.Heute.Zuendungen.Value, statistik.Gestern.Zuendungen.Value)
+	}
+
+	now = time.Date(2026, 2, 15, 1, 0, 0, 0, time.UTC)
+	b.processLine("z 01:00:00 Kessel Aus")
+	if got := statistik.Gestern.LeistungsbrandStunden.Value; got != 4.67 {
+		t.Fatalf("expected 4.67 Leistungsbrand hours yesterday, got %v", got)
+	}
+	if got := statistik.Heute.LeistungsbrandStunden.Value; got != 1 {
+		t.Fatalf("expected 1 Leistungsbrand hour today, got %v", got)
+	}
+	if got := statistik.Saison.LeistungsbrandStunden.Value; got != 5.67 {
+		t.Fatalf("expected 5.67 Leistungsbrand hours in the season, got %v", got)
+	}
+	if statistik.SaisonName.Value != "2025/26" {
+		t.Fatalf("unexpected season %q", statistik.SaisonName.Value)
+	}
+	if got := testutil.ToFloat64(leistungsbrandSecondsTotal.WithLabelValues("statistik")); got != 13212+7200 {
+		t.Fatalf("expected counter %d, got %v", 13212+7200, got)
+	}
+
+	// a new season starts in July
+	now = time.Date(2026, 7, 1, 0, 0, 1, 0, time.UTC)
+	b.rolloverStatistik()
+	if statistik.Saison.Zuendungen.Value != 0 || statistik.Gestern.Zuendungen.Value != 0 || statistik.SaisonName.Value != "2026/27" {
+		t.Fatalf("expected new season, got %d %d %q", statistik.Saison.Zuendungen.Value, statistik.Gestern.Zuendungen.Value, statistik.SaisonName.Value)
+	}
+}
+
+func TestStatistik_BoilerClockAhead(t *testing.T) {
+	b := newBoiler("statistik-ahead", "Statistik Ahead")
+	now := time.Date(2026, 2, 14, 23, 0, 0, 0, time.UTC)
+	b.now = func() time.Time { return now }
+	statistik := b.statistik
+
+	b.processLine("z 23:49:00 Kessel Zündung")
+	// the boiler clock is 49 minutes ahead, its midnight is not the one of the host
+	now = time.Date(2026, 2, 14, 23, 31, 0, 0, time.UTC)
+	b.processLine("z 00:20:00 Kessel Zündung")
+
+	if statistik.day != "2026-02-14" {
+		t.Fatalf("expected the day of the host clock, got %s", statistik.day)
+	}
+	if statistik.Heute.Zuendungen.Value != 2 || statistik.Gestern.Zuendungen.Value != 0 {
+		t.Fatalf("expected 2 Zündungen today, got %d today and %d yesterday", statistik.Heute.Zuendungen.Value, statistik.Gestern.Zuendungen.Value)
+	}
+
+	now = time.Date(2026, 2, 15, 0, 0, 1, 0, time.UTC)
+	b.rolloverStatistik()
+	if statistik.Heute.Zuendungen.Value != 0 || statistik.Gestern.Zuendungen.Value != 2 {
+		t.Fatalf("expected 2 Zündungen yesterday after midnight, got %d today and %d yesterday", statistik.Heute.Zuendungen.Value, statistik.Gestern.Zuendungen.Value)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// StatusValue is the current value of a status field in the /status response
+type StatusValue struct {
+	Id    string              `json:"id"`
+	Node  string              `json:"node"`
+	Name  MultiLanguageString `json:"name"`
+	Unit  string              `json:"unit"`
+	Value any                 `json:"value"`
+	// Updated is the receive time of the value in RFC 3339 format
+	Updated string `json:"updated,omitempty"`
+	// Age is the number of seconds since the value was received
+	Age *float64 `json:"age,omitempty"`
+}
+
+type StatusResponse struct {
+	Boiler string        `json:"boiler"`
+	Name   string        `json:"name"`
+	Values []StatusValue `json:"values"`
+}
+
+// status returns the current value of the field, the value is null if no
+// value was received yet.
+func (field *StatusField[T]) status(now time.Time) StatusValue {
+	status := StatusValue{
+		Id:   field.Id,
+		Node: field.Node,
+		Name: field.Name,
+		Unit: field.Unit,
+	}
+	if !field.Updated.IsZero() {
+		age := now.Sub(field.Updated).Seconds()
+		status.Value = field.Value
+		status.Updated = field.Updated.Format(time.RFC3339)
+		status.Age = &age
+	}
+	return status
+}
+
+// statusValues returns the values of all published status fields of the
+// boiler. The caller must hold b.mu.
+func (b *Boiler) statusValues(now time.Time) []StatusValue {
+	values := []StatusValue{b.meldung.status(now)}
+	for i, field := range b.statusRecord.bound {
+		values = append(values, field.status(now))
+		if i < len(b.statusRecord.checks) && b.statusRecord.checks[i] != nil {
+			values = append(values, b.statusRecord.checks[i].SensorFault.status(now))
+		}
+	}
+	kessel := b.kesselRecord
+	values = append(values,
+		kessel.DauerLetzteZuendung.status(now),
+		kessel.DauerLetzterLeistungsbrand.status(now),
+		kessel.AnzahlZuendungen.status(now),
+		kessel.LetzteZuendung.status(now),
+		kessel.LetzterLeistungsbrand.status(now),
+		kessel.Zustand.status(now),
+		kessel.ZustandSeit.status(now))
+	for _, phase := range zuendungPhasen {
+		values = append(values, kessel.DauerZuendungPhase[phase].status(now))
+	}
+	fehlzuendung := b.fehlzuendung
+	values = append(values,
+		fehlzuendung.AnzahlFehlzuendungen.status(now),
+		fehlzuendung.AnzahlErfolgreicheZuendungen.status(now),
+		fehlzuendung.Quote.status(now),
+		fehlzuendung.LetzteFehlzuendung.status(now),
+		fehlzuendung.Grund.status(now))
+	values = append(values,
+		b.uhr.Abweichung.status(now),
+		b.uhr.Alarm.status(now))
+	entaschung := b.entaschung
+	values = append(values,
+		entaschung.AnzahlEntaschungen.status(now),
+		entaschung.DauerLetzteEntaschung.status(now))
+	for _, schritt := range entaschungSchritte {
+		values = append(values, entaschung.DauerSchritt[schritt.step].status(now))
+	}
+	values = append(values,
+		entaschung.LetzteEntaschung.status(now),
+		entaschung.MaxStromAscheaustragung.status(now))
+	values = append(values, b.statistik.Heute.statusValues(now)...)
+	values = append(values, b.statistik.Gestern.statusValues(now)...)
+	values = append(values, b.statistik.Saison.statusValues(now)...)
+	values = append(values, b.statistik.SaisonName.status(now))
+	stoerung := b.stoerungRecord
+	values = append(values,
+		stoerung.StoerungNr.status(now),
+		stoerung.StoerungText.status(now),
+		stoerung.StoerungActive.status(now),
+		stoerung.LastActive.status(now))
+	return values
+}
+
+// handleStatus returns the current values of the boiler as JSON
+func (b *Boiler) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	b.mu.Lock()
+	status := StatusResponse{
+		Boiler: b.ID,
+		Name:   b.Name,
+		Values: b.statusValues(time.Now()),
+	}
+	b.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandleStatus(t *testing.T) {
+	b := newBoiler("status", "Status")
+	b.processLine(hsvLine(72))
+	if b.statusRecord.BoilerTemperature.Value != 72 || b.statusRecord.BoilerTemperature.Updated.IsZero() {
+		t.Fatalf("expected kesselTemperatur 72 with receive time, got %+v", b.statusRecord.BoilerTemperature)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status/{boiler}", boilerHandler([]*Boiler{b}, (*Boiler).handleStatus))
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/status/status", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var status StatusResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.Boiler != "status" {
+		t.Fatalf("unexpected boiler %q", status.Boiler)
+	}
+	values := make(map[string]StatusValue)
+	for _, value := range status.Values {
+		values[value.Node+"/"+value.Id] = value
+	}
+
+	temperature := values["prozesswerte/kesselTemperatur"]
+	if temperature.Value != 72.0 || temperature.Unit != "°C" || temperature.Name.DE != "Kesseltemperatur" {
+		t.Fatalf("unexpected kesselTemperatur %+v", temperature)
+	}
+	if temperature.Age == nil || *temperature.Age < 0 || temperature.Updated == "" {
+		t.Fatalf("expected age and receive time of kesselTemperatur, got %+v", temperature)
+	}
+	if _, ok := values["prozesswerte/kesselTemperaturSensorFault"]; !ok {
+		t.Fatalf("expected kesselTemperaturSensorFault in %v", values)
+	}
+
+	zuendungen, ok := values["kessel/AnzahlZuendungen"]
+	if !ok || zuendungen.Value != nil || zuendungen.Age != nil {
+		t.Fatalf("expected AnzahlZuendungen without value, got %+v", zuendungen)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// recordWatchdog tracks when the boiler sent its last pm and z records. The
+// boiler sends a pm record every second, so missing pm records mean that the
+// data stream stalled. z records are only sent on events, their age is reported
+// but does not count as a stall.
+type recordWatchdog struct {
+	// timeout is the maximum age of the last pm record
+	timeout time.Duration
+	now     func() time.Time
+
+	mu      sync.Mutex
+	started time.Time
+	last    map[string]time.Time
+}
+
+func newRecordWatchdog(timeout time.Duration) *recordWatchdog {
+	return &recordWatchdog{
+		timeout: timeout,
+		now:     time.Now,
+		started: time.Now(),
+		last:    make(map[string]time.Time),
+	}
+}
+
+// Seen records that a record of recordType ("pm" or "z") was received.
+func (w *recordWatchdog) Seen(recordType string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.last[recordType] = w.now()
+}
+
+// Age returns the time since the last record of recordType. If none has been
+// received yet the time since the start of the watchdog is returned.
+func (w *recordWatchdog) Age(recordType string) time.Duration {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	last, ok := w.last[recordType]
+	if !ok {
+		last = w.started
+	}
+	return w.now().Sub(last)
+}
+
+// Stalled reports whether the last pm record is older than the timeout.
+func (w *recordWatchdog) Stalled() bool {
+	return w.timeout > 0 && w.Age("pm") > w.timeout
+}
+
+// Collectors returns the hargassner_last_record_age_seconds gauges of the watchdog.
+func (w *recordWatchdog) Collectors(boilerID string) []prometheus.Collector {
+	var collectors []prometheus.Collector
+	for _, recordType := range []string{"pm", "z"} {
+		collectors = append(collectors, prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name:        "hargassner_last_record_age_seconds",
+			Help:        "Alter des letzten empfangenen Datensatzes",
+			ConstLabels: prometheus.Labels{"boiler": boilerID, "type": recordType},
+		}, func() float64 {
+			return w.Age(recordType).Seconds()
+		}))
+	}
+	return collectors
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/creativeprojects/go-homie"
+)
+
+func TestRecordWatchdog_Stall(t *testing.T) {
+	now := time.Date(2026, 2, 14, 13, 0, 0, 0, time.Local)
+	w := newRecordWatchdog(time.Minute)
+	w.now = func() time.Time { return now }
+	w.started = now
+
+	w.Seen("pm")
+	now = now.Add(30 * time.Second)
+	if w.Stalled() {
+		t.Fatalf("expected no stall after 30s")
+	}
+	if age := w.Age("pm"); age != 30*time.Second {
+		t.Fatalf("expected pm age 30s, got %s", age)
+	}
+
+	// z records don't prevent a stall
+	w.Seen("z")
+	now = now.Add(31 * time.Second)
+	if !w.Stalled() {
+		t.Fatalf("expected stall after 61s without pm record")
+	}
+
+	w.Seen("pm")
+	if w.Stalled() {
+		t.Fatalf("expected recovery after new pm record")
+	}
+}
+
+func TestReadinessProbe_FailsOnStall(t *testing.T) {
+	now := time.Now()
+	first := newBoiler("hargassner", "Hargassner Heizung")
+	second := newBoiler("hargassner-2", "Hargassner Heizung 2")
+	for _, b := range []*Boiler{first, second} {
+		b.watchdog.now = func() time.Time { return now }
+		b.watchdog.Seen("pm")
+	}
+	probe := readinessProbe([]*Boiler{first, second})
+
+	rr := httptest.NewRecorder()
+	probe(rr, httptest.NewRequest("GET", "/readiness", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	// only the second boiler stalls
+	now = now.Add(2 * time.Minute)
+	first.watchdog.now = func() time.Time { return now.Add(-2 * time.Minute) }
+	rr = httptest.NewRecorder()
+	probe(rr, httptest.NewRequest("GET", "/readiness", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected %d, got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+}
+
+// TestUpdateHomieState_Concurrent switches the Homie state while records are
+// processed and the attributes are read, run with -race.
+func TestUpdateHomieState_Concurrent(t *testing.T) {
+	b := newBoiler("homie-state", "Homie State")
+	b.raw = newRawPmNode(b.device, b.ID)
+	// the setter is called with b.mu held, like the publishing of the monitor
+	published := make(map[string]string)
+	b.device.OnSet(func(topic, value string, dataType homie.PropertyType) {
+		published[topic] = value
+	})
+
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		for i := range 200 {
+			b.sourceConnected.Store(i%2 == 0)
+			b.updateHomieState()
+		}
+	})
+	wg.Go(func() {
+		for i := range 200 {
+			b.handleLine(hsvLine(60 + i%2))
+			b.handleLine("z 14:10:40 Kessel Zündung")
+		}
+	})
+	wg.Go(func() {
+		for range 200 {
+			b.mu.Lock()
+			b.device.GetHomieAttributes()
+			b.mu.Unlock()
+		}
+	})
+	wg.Wait()
+
+	b.sourceConnected.Store(true)
+	b.watchdog.Seen("pm")
+	b.updateHomieState()
+	if state := published[b.device.GetStateTopic()]; state != string(homie.StateReady) {
+		t.Fatalf("expected state ready, got %q", state)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"time"
+)
+
+// anchorZTime returns the time of a z record from the time of day clock
+// ("15:04:05") reported by the boiler. The date is taken from the receive
+// time: of the candidates on the day before, the same day and the day after
+// the one closest to received is chosen, so events shortly before midnight
+// received after midnight get the date of the previous day. Times of day which
+// occur twice at the end of daylight saving time are resolved the same way.
+func anchorZTime(clock string, received time.Time) (time.Time, error) {
+	timeOfDay, err := time.Parse("15:04:05", clock)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var anchored time.Time
+	for days := -1; days <= 1; days++ {
+		day := received.AddDate(0, 0, days)
+		candidate := time.Date(day.Year(), day.Month(), day.Day(), timeOfDay.Hour(), timeOfDay.Minute(), timeOfDay.Second(), 0, received.Location())
+		// the time of day may occur a second time after the clock was set back
+		for _, c := range []time.Time{candidate, candidate.Add(time.Hour), candidate.Add(-time.Hour)} {
+			if c.Format("15:04:05") != clock && c != candidate {
+				continue
+			}
+			if anchored.IsZero() || absDuration(c.Sub(received)) < absDuration(anchored.Sub(received)) {
+				anchored = c
+			}
+		}
+	}
+	return anchored, nil
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+// testClock is the receive time of the records in tests. The z record times
+// of the tests lie within 12 hours of it, so they are anchored to its date.
+func testClock() time.Time {
+	return time.Date(2026, 2, 14, 16, 0, 0, 0, time.UTC)
+}
+
+func TestAnchorZTime(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name     string
+		clock    string
+		received time.Time
+		want     time.Time
+	}{
+		{"same day", "14:10:40", time.Date(2026, 2, 14, 13, 21, 44, 0, berlin), time.Date(2026, 2, 14, 14, 10, 40, 0, berlin)},
+		{"before midnight received after midnight", "23:59:58", time.Date(2026, 2, 15, 0, 0, 1, 0, berlin), time.Date(2026, 2, 14, 23, 59, 58, 0, berlin)},
+		{"after midnight received before midnight", "00:00:03", time.Date(2026, 2, 14, 23, 59, 59, 0, berlin), time.Date(2026, 2, 15, 0, 0, 3, 0, berlin)},
+		// 2026-10-25 02:30 occurs twice in Berlin, first in CEST (00:30 UTC) then in CET (01:30 UTC)
+		{"first occurrence at end of DST", "02:30:00", time.Date(2026, 10, 25, 0, 31, 0, 0, time.UTC), time.Date(2026, 10, 25, 0, 30, 0, 0, time.UTC)},
+		{"second occurrence at end of DST", "02:30:00", time.Date(2026, 10, 25, 1, 31, 0, 0, time.UTC), time.Date(2026, 10, 25, 1, 30, 0, 0, time.UTC)},
+	} {
+		received := test.received.In(berlin)
+		got, err := anchorZTime(test.clock, received)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !got.Equal(test.want) {
+			t.Errorf("%s: expected %s, got %s", test.name, test.want, got)
+		}
+	}
+
+	if _, err := anchorZTime("25:00:00", testClock()); err == nil {
+		t.Fatalf("expected error for invalid time")
+	}
+}
+
+func TestHandleZRecord_DurationOverMidnightAndDST(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := newBoiler("ztime", "Z Time")
+	received := time.Date(2026, 2, 14, 23, 30, 5, 0, berlin)
+	b.now = func() time.Time { return received }
+
+	b.processLine("z 23:30:00 Kessel Leistungsbrand")
+	received = time.Date(2026, 2, 15, 1, 10, 3, 0, berlin)
+	b.processLine("z 01:10:00 Kessel Aus")
+	if got := b.kesselRecord.DauerLetzterLeistungsbrand.Value; got != 6000 {
+		t.Fatalf("expected DauerLetzterLeistungsbrand 6000 over midnight, got %d", got)
+	}
+	if got := b.kesselRecord.LetzterLeistungsbrand.Value; got != "2026-02-14T23:30:00+01:00" {
+		t.Fatalf("unexpected LetzterLeistungsbrand %q", got)
+	}
+
+	// the clock is set back from 03:00 CEST to 02:00 CET
+	received = time.Date(2026, 10, 25, 1, 50, 0, 0, berlin)
+	b.processLine("z 01:50:00 Kessel Leistungsbrand")
+	received = time.Date(2026, 10, 25, 2, 10, 0, 0, time.UTC).In(berlin)
+	b.processLine("z 03:10:00 Kessel Aus")
+	// 01:50 CEST to 03:10 CET are 2 hours and 20 minutes
+	if got := b.kesselRecord.DauerLetzterLeistungsbrand.Value; got != 8400 {
+		t.Fatalf("expected DauerLetzterLeistungsbrand 8400 over the end of DST, got %d", got)
+	}
+}
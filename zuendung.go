@@ -0,0 +1,117 @@
+package main
+
+import (
+	"time"
+
+	"github.com/creativeprojects/go-homie"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// zuendungPhasen are the sub phases of an ignition reported as
+// "z <time> Kessel Zündung <phase>"
+var zuendungPhasen = []string{"Start", "Einschub", "Pause", "Reduziert"}
+
+var zuendungPhaseDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "hargassner_zuendung_phase_duration_seconds",
+	Help:    "Dauer der Phasen der Zündung",
+	Buckets: prometheus.ExponentialBuckets(10, 2, 8),
+}, []string{"boiler", "phase"})
+
+func init() {
+	prometheus.MustRegister(zuendungPhaseDuration)
+}
+
+// registerZuendungPhasen publishes the durations of the sub phases of the last
+// ignition as properties DauerZuendung<phase>.
+func (r *KesselRecord) registerZuendungPhasen(node *homie.Node, boilerID string) {
+	r.DauerZuendungPhase = make(map[string]*StatusField[int])
+	for _, phase := range zuendungPhasen {
+		field := &StatusField[int]{
+			Id:   "DauerZuendung" + phase,
+			Name: MultiLanguageString{EN: "Duration Last Ignition Phase " + phase, DE: "Dauer letzte Zündung Phase " + phase},
+			Unit: "s",
+		}
+		registerStatusField(field, node, "kessel", boilerID)
+		r.DauerZuendungPhase[phase] = field
+	}
+}
+
+// startZuendung starts tracking the sub phases of an ignition
+func (r *KesselRecord) startZuendung() {
+	r.zuendungPhase = ""
+	r.zuendungPhasenDauer = make(map[string]time.Duration)
+}
+
+// startZuendungPhase ends the current sub phase of the ignition at timestamp
+// and starts phase.
+func (r *KesselRecord) startZuendungPhase(phase string, timestamp time.Time) {
+	if r.zuendungPhasenDauer == nil {
+		// the start of the ignition was missed
+		r.startZuendung()
+	}
+	r.endZuendungPhase(timestamp)
+	r.zuendungPhase = phase
+	r.zuendungPhaseStart = timestamp
+}
+
+func (r *KesselRecord) endZuendungPhase(timestamp time.Time) {
+	if r.zuendungPhase != "" {
+		r.zuendungPhasenDauer[r.zuendungPhase] += timestamp.Sub(r.zuendungPhaseStart)
+		r.zuendungPhase = ""
+	}
+}
+
+// endZuendung publishes the durations of the sub phases when the ignition ends
+// at timestamp. Phases which did not occur have the duration 0.
+func (r *KesselRecord) endZuendung(timestamp time.Time) {
+	if r.zuendungPhasenDauer == nil {
+		return
+	}
+	r.endZuendungPhase(timestamp)
+	for _, phase := range zuendungPhasen {
+		duration, ok := r.zuendungPhasenDauer[phase]
+		r.DauerZuendungPhase[phase].SetValue(int(duration.Seconds()))
+		if ok {
+			zuendungPhaseDuration.WithLabelValues(r.boilerID, phase).Observe(duration.Seconds())
+		}
+	}
+	r.zuendungPhasenDauer = nil
+}
+
+// zuendungState is the persisted progress of the sub phases of an ignition
+type zuendungState struct {
+	// Phase is the current sub phase started at PhaseStart, empty if none
+	Phase      string     `json:"phase,omitempty"`
+	PhaseStart *time.Time `json:"phaseStart,omitempty"`
+	// Dauer holds the durations of the finished sub phases in seconds
+	Dauer map[string]float64 `json:"dauer"`
+}
+
+// persistZuendung returns the progress of the ignition in progress, nil
+// outside of an ignition
+func (r *KesselRecord) persistZuendung() *zuendungState {
+	if r.zuendungPhasenDauer == nil {
+		return nil
+	}
+	state := &zuendungState{Phase: r.zuendungPhase, Dauer: make(map[string]float64)}
+	if r.zuendungPhase != "" {
+		start := r.zuendungPhaseStart
+		state.PhaseStart = &start
+	}
+	for phase, duration := range r.zuendungPhasenDauer {
+		state.Dauer[phase] = duration.Seconds()
+	}
+	return state
+}
+
+// restoreZuendung continues the sub phases of a persisted ignition
+func (r *KesselRecord) restoreZuendung(state *zuendungState) {
+	r.startZuendung()
+	for phase, seconds := range state.Dauer {
+		r.zuendungPhasenDauer[phase] = time.Duration(seconds * float64(time.Second))
+	}
+	if state.Phase != "" && state.PhaseStart != nil {
+		r.zuendungPhase = state.Phase
+		r.zuendungPhaseStart = *state.PhaseStart
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestZuendungPhasen(t *testing.T) {
+	b := newBoiler("zuendung-phasen", "Zündung Phasen")
+	b.now = testClock
+	kessel := b.kesselRecord
+
+	for _, line := range []string{
+		"z 14:10:40 Kessel Zündung",
+		"z 14:10:40 Kessel Zündung Start",
+		"z 14:12:20 Kessel Zündung Einschub",
+		"z 14:15:20 Kessel Zündung Pause",
+		"z 14:17:20 Kessel Zündung Reduziert",
+		"z 14:20:20 Kessel Leistungsbrand",
+	} {
+		b.processLine(line)
+	}
+	for phase, want := range map[string]int{"Start": 100, "Einschub": 180, "Pause": 120, "Reduziert": 180} {
+		if got := kessel.DauerZuendungPhase[phase].Value; got != want {
+			t.Errorf("expected duration %d of phase %s, got %d", want, phase, got)
+		}
+	}
+	if b.device.Node("kessel").Property("DauerZuendungEinschub") == nil {
+		t.Fatalf("expected Homie property DauerZuendungEinschub")
+	}
+
+	// a phase which does not occur in the next ignition has the duration 0
+	for _, line := range []string{
+		"z 18:05:00 Kessel Zündung",
+		"z 18:05:00 Kessel Zündung Start",
+		"z 18:06:00 Kessel Zündung Einschub",
+		"z 18:08:00 Kessel Zündung Start",
+		"z 18:09:00 Kessel Aus",
+	} {
+		b.processLine(line)
+	}
+	for phase, want := range map[string]int{"Start": 120, "Einschub": 120, "Pause": 0, "Reduziert": 0} {
+		if got := kessel.DauerZuendungPhase[phase].Value; got != want {
+			t.Errorf("expected duration %d of phase %s after second ignition, got %d", want, phase, got)
+		}
+	}
+
+	// Pause occurred in the first ignition only
+	var metric dto.Metric
+	if err := zuendungPhaseDuration.WithLabelValues("zuendung-phasen", "Pause").(prometheus.Metric).Write(&metric); err != nil {
+		t.Fatal(err)
+	}
+	if got := metric.GetHistogram().GetSampleCount(); got != 1 {
+		t.Fatalf("expected 1 observation of phase Pause, got %d", got)
+	}
+}